@@ -0,0 +1,151 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// envListenPID is the PID of the process the listeners are passed to, by systemd socket activation
+	envListenPID = "LISTEN_PID"
+	// envListenFDs is the number of listeners passed by systemd socket activation
+	envListenFDs = "LISTEN_FDS"
+	// envListenFDNames is the colon separated names of the listeners passed by systemd socket activation
+	envListenFDNames = "LISTEN_FDNAMES"
+	// listenFDsStart is the first file descriptor passed by systemd socket activation
+	listenFDsStart = 3
+
+	// unixAddrPrefix is the prefix of Unix domain socket addresses, e.g. "unix:/run/app.sock"
+	unixAddrPrefix = "unix:"
+)
+
+// ErrSocketActivation is the error returned when the listeners passed by systemd socket activation are invalid
+var ErrSocketActivation = errors.New("invalid socket activation")
+
+// SystemdListeners returns the listeners passed by systemd socket activation, in order,
+// along with their names as set by FileDescriptorName= of the socket unit.
+// No listeners are returned if the process was not socket activated.
+// The environment variables are unset, so that they are not inherited by child processes.
+func SystemdListeners() ([]net.Listener, []string, error) {
+	defer func() {
+		_ = os.Unsetenv(envListenPID)
+		_ = os.Unsetenv(envListenFDs)
+		_ = os.Unsetenv(envListenFDNames)
+	}()
+	return activatedListeners(os.Getenv, listenFDsStart)
+}
+
+// activatedListeners returns the listeners of the file descriptors starting from firstFD,
+// as described by the socket activation environment variables
+func activatedListeners(getenv func(string) string, firstFD int) ([]net.Listener, []string, error) {
+	pid := getenv(envListenPID)
+	if pid == "" || pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil, nil
+	}
+
+	count, err := strconv.Atoi(getenv(envListenFDs))
+	if err != nil || count < 0 {
+		return nil, nil, fmt.Errorf("%w: %s=%q", ErrSocketActivation, envListenFDs, getenv(envListenFDs))
+	}
+
+	names := strings.Split(getenv(envListenFDNames), ":")
+	listeners := make([]net.Listener, 0, count)
+	lNames := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		// FileListener duplicates the file descriptor, so the inherited one is closed
+		file := os.NewFile(uintptr(firstFD+i), name)
+		l, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, nil, fmt.Errorf("%w: file descriptor %d: %s", ErrSocketActivation, firstFD+i, err.Error())
+		}
+		listeners = append(listeners, l)
+		lNames = append(lNames, name)
+	}
+	return listeners, lNames, nil
+}
+
+// pickActivated returns the HTTP and HTTPS listeners among the activated listeners.
+// Listeners named "http" and "https" are picked by name, otherwise the first one is used for HTTP
+// and the second one for HTTPS. The remaining listeners are closed.
+func pickActivated(listeners []net.Listener, names []string) (net.Listener, net.Listener) {
+	httpIdx, httpsIdx := -1, -1
+	for i, name := range names {
+		switch name {
+		case "http":
+			httpIdx = i
+		case "https":
+			httpsIdx = i
+		}
+	}
+	if httpIdx < 0 && httpsIdx < 0 {
+		httpIdx = 0
+		if len(listeners) > 1 {
+			httpsIdx = 1
+		}
+	}
+
+	var httpL, httpsL net.Listener
+	for i, l := range listeners {
+		switch i {
+		case httpIdx:
+			httpL = l
+		case httpsIdx:
+			httpsL = l
+		default:
+			LOGHANDLER.Warn("unused socket activation listener", names[i], l.Addr().String())
+			_ = l.Close()
+		}
+	}
+	return httpL, httpsL
+}
+
+// listen returns the listener for the address, which is either "host:port",
+// or "unix:/path/to/file.sock" for a Unix domain socket with the file mode (if not zero).
+// keepAlive is the period of the TCP keep-alive probes, as in net.ListenConfig
+func listen(addr string, mode os.FileMode, keepAlive time.Duration) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixAddrPrefix) {
+		lc := &net.ListenConfig{KeepAlive: keepAlive}
+		return lc.Listen(context.Background(), "tcp", addr)
+	}
+
+	path := strings.TrimPrefix(addr, unixAddrPrefix)
+	// a stale socket file, left behind by a previous process, prevents listening.
+	// It is removed only if nothing is accepting connections on it
+	info, err := os.Stat(path)
+	if err == nil && info.Mode()&os.ModeSocket != 0 {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			_ = conn.Close()
+		} else {
+			_ = os.Remove(path)
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		err = os.Chmod(path, mode)
+		if err != nil {
+			_ = l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,208 @@
+//go:build unix
+
+package web
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// inheritedFDs returns the duplicated file descriptors of new TCP listeners,
+// the way they would be passed by systemd. The file descriptors are consecutive.
+func inheritedFDs(t *testing.T, count int) int {
+	t.Helper()
+	first := -1
+	for i := 0; i < count; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := l.(*net.TCPListener).File()
+		_ = l.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd, err := syscall.Dup(int(f.Fd()))
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first < 0 {
+			first = fd
+		} else if fd != first+i {
+			t.Skip("file descriptors are not consecutive")
+		}
+	}
+	return first
+}
+
+func TestActivatedListeners(t *testing.T) {
+	// not parallel, so that the file descriptors are consecutive
+	first := inheritedFDs(t, 2)
+	env := map[string]string{
+		envListenPID:     strconv.Itoa(os.Getpid()),
+		envListenFDs:     "2",
+		envListenFDNames: "admin:http",
+	}
+	listeners, names, err := activatedListeners(func(key string) string { return env[key] }, first)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(listeners) != 2 || names[0] != "admin" || names[1] != "http" {
+		t.Errorf("Expected 2 listeners named 'admin' and 'http', got %d %v", len(listeners), names)
+		return
+	}
+
+	httpL, httpsL := pickActivated(listeners, names)
+	if httpL != listeners[1] || httpsL != nil {
+		t.Errorf("Expected the listener named 'http' to be picked, got %v %v", httpL, httpsL)
+	}
+	_ = httpL.Close()
+
+	// the listeners of another process are ignored
+	env[envListenPID] = "1"
+	listeners, _, err = activatedListeners(func(key string) string { return env[key] }, first)
+	if err != nil || listeners != nil {
+		t.Errorf("Expected no listeners, got %v (error '%v')", listeners, err)
+	}
+
+	env[envListenPID] = strconv.Itoa(os.Getpid())
+	env[envListenFDs] = "x"
+	_, _, err = activatedListeners(func(key string) string { return env[key] }, first)
+	if !errors.Is(err, ErrSocketActivation) {
+		t.Errorf("Expected error '%v', got '%v'", ErrSocketActivation, err)
+	}
+}
+
+func TestPickActivated(t *testing.T) {
+	t.Parallel()
+	listeners := make([]net.Listener, 0, 3)
+	for i := 0; i < 3; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		listeners = append(listeners, l)
+	}
+
+	httpL, httpsL := pickActivated(listeners, []string{"web.socket", "web.socket", "web.socket"})
+	if httpL != listeners[0] || httpsL != listeners[1] {
+		t.Error("Expected the first and the second listeners to be picked in order")
+	}
+	// the unused listener is closed
+	if _, err := listeners[2].Accept(); err == nil {
+		t.Error("Expected the unused listener to be closed")
+	}
+}
+
+func TestRouter_RunUnixSocket(t *testing.T) {
+	t.Parallel()
+	sock := filepath.Join(t.TempDir(), "web.sock")
+	router := NewRouter(&Config{
+		Listen:          unixAddrPrefix + sock,
+		SocketMode:      0600,
+		ShutdownTimeout: time.Second,
+	}, &Route{
+		Name:          "home",
+		Method:        http.MethodGet,
+		Pattern:       "/",
+		TrailingSlash: true,
+		Handlers:      []http.HandlerFunc{dummyHandler},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- router.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Expected no error, got '%v'", err)
+		}
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+			},
+		},
+	}
+	var (
+		resp *http.Response
+		err  error
+	)
+	for i := 0; i < 50; i++ {
+		resp, err = client.Get("http://unix/")
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, _ = ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected response status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	info, err := os.Stat(sock)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected socket mode %v, got %v", os.FileMode(0600), info.Mode().Perm())
+	}
+}
+
+func TestRouter_Serve(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewRouter(&Config{ShutdownTimeout: time.Second}, &Route{
+		Name:          "home",
+		Method:        http.MethodGet,
+		Pattern:       "/",
+		TrailingSlash: true,
+		Handlers:      []http.HandlerFunc{dummyHandler},
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- router.Serve(l)
+	}()
+
+	resp, err := http.Get("http://" + l.Addr().String() + "/")
+	if err != nil {
+		t.Error(err.Error())
+	} else {
+		_ = resp.Body.Close()
+	}
+
+	_ = router.Shutdown()
+	if err := <-done; err != nil {
+		t.Errorf("Expected no error after shutdown, got '%v'", err)
+	}
+
+	err = router.ServeTLS(l)
+	if !errors.Is(err, ErrNoCertificate) {
+		t.Errorf("Expected error '%v', got '%v'", ErrNoCertificate, err)
+	}
+}
@@ -0,0 +1,240 @@
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pchchv/web/devcert"
+)
+
+var (
+	// ErrNoCertificates is the error returned when a CertReloader is created without any certificate
+	ErrNoCertificates = errors.New("no certificates provided")
+	// ErrCertificateExpired is the error returned when a certificate being loaded has expired
+	ErrCertificateExpired = errors.New("certificate has expired")
+)
+
+// CertPair is a certificate and its private key, served for the hostnames
+type CertPair struct {
+	// CertFile is the path to the certificate file
+	CertFile string `json:"certFile"`
+	// KeyFile is the path to the private key file
+	KeyFile string `json:"keyFile"`
+	// Hosts are the hostnames the certificate is served for, e.g. "example.com" or "*.example.com".
+	// If empty, the DNS names of the certificate are used
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// loadedPair is a certificate pair along with the state of its files when it was last loaded
+type loadedPair struct {
+	CertPair
+	cert  *tls.Certificate
+	hosts []string
+	stamp string
+}
+
+// CertReloader serves the certificates with tls.Config.GetCertificate, and reloads them
+// when the files change or on SIGHUP, without restarting the server.
+// A new pair which fails to load is rejected, and the previous one is kept in use.
+type CertReloader struct {
+	lock  sync.RWMutex
+	pairs []*loadedPair
+	// byHost maps the lower case hostnames to the certificates
+	byHost map[string]*tls.Certificate
+}
+
+// NewCertReloader returns a CertReloader for the certificate pairs. The first pair is the default one,
+// served when the client does not send a server name (SNI) or no other certificate matches it.
+func NewCertReloader(pairs ...CertPair) (*CertReloader, error) {
+	if len(pairs) == 0 {
+		return nil, ErrNoCertificates
+	}
+
+	cr := &CertReloader{
+		pairs: make([]*loadedPair, 0, len(pairs)),
+	}
+	for _, pair := range pairs {
+		lp := &loadedPair{CertPair: pair}
+		err := lp.load()
+		if err != nil {
+			return nil, err
+		}
+		cr.pairs = append(cr.pairs, lp)
+	}
+	cr.index()
+	return cr, nil
+}
+
+// fileStamp returns the modification times and sizes of the files, to detect changes
+func fileStamp(files ...string) string {
+	var sb strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			sb.WriteString("-;")
+			continue
+		}
+		fmt.Fprintf(&sb, "%d:%d;", info.ModTime().UnixNano(), info.Size())
+	}
+	return sb.String()
+}
+
+// load loads the pair from its files, it keeps the previous certificate if the new one is invalid
+func (lp *loadedPair) load() error {
+	lp.stamp = fileStamp(lp.CertFile, lp.KeyFile)
+
+	cert, err := tls.LoadX509KeyPair(lp.CertFile, lp.KeyFile)
+	if err != nil {
+		return fmt.Errorf("%s: %w", lp.CertFile, err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", lp.CertFile, err)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return fmt.Errorf("%s: %w on %s", lp.CertFile, ErrCertificateExpired, leaf.NotAfter.Format(time.RFC3339))
+	}
+	cert.Leaf = leaf
+
+	hosts := lp.Hosts
+	if len(hosts) == 0 {
+		hosts = leaf.DNSNames
+	}
+	lp.cert = &cert
+	lp.hosts = hosts
+	return nil
+}
+
+// index maps the hostnames to the certificates, the first pair matching a hostname wins
+func (cr *CertReloader) index() {
+	byHost := make(map[string]*tls.Certificate)
+	for _, lp := range cr.pairs {
+		for _, host := range lp.hosts {
+			host = strings.ToLower(host)
+			if _, ok := byHost[host]; !ok {
+				byHost[host] = lp.cert
+			}
+		}
+	}
+	cr.byHost = byHost
+}
+
+// Reload reloads all the certificate pairs. The pairs which fail to load keep their previous certificate,
+// and their errors are returned.
+func (cr *CertReloader) Reload() error {
+	return cr.reload(false)
+}
+
+// reload reloads the certificate pairs, or only the ones whose files changed
+func (cr *CertReloader) reload(changedOnly bool) error {
+	cr.lock.Lock()
+	defer cr.lock.Unlock()
+
+	var errs []error
+	reloaded := false
+	for _, lp := range cr.pairs {
+		if changedOnly && fileStamp(lp.CertFile, lp.KeyFile) == lp.stamp {
+			continue
+		}
+		reloaded = true
+		err := lp.load()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if reloaded {
+		cr.index()
+	}
+	return errors.Join(errs...)
+}
+
+// GetCertificate returns the certificate for the server name of the client hello,
+// it is meant to be used as tls.Config.GetCertificate
+func (cr *CertReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.lock.RLock()
+	defer cr.lock.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name != "" {
+		if cert, ok := cr.byHost[name]; ok {
+			return cert, nil
+		}
+		// wildcard certificates match a single label
+		if idx := strings.IndexByte(name, '.'); idx > 0 {
+			if cert, ok := cr.byHost["*"+name[idx:]]; ok {
+				return cert, nil
+			}
+		}
+	}
+	return cr.pairs[0].cert, nil
+}
+
+// Watch reloads the certificates on SIGHUP, and when their files change if interval is not zero.
+// Errors are logged. It blocks until ctx is cancelled.
+func (cr *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			LOGHANDLER.Info("reloading certificates")
+			err = cr.reload(false)
+		case <-tick:
+			err = cr.reload(true)
+		}
+		if err != nil {
+			LOGHANDLER.Error("certificate reload failed, keeping the previous certificate:", err.Error())
+		}
+	}
+}
+
+// certPairs returns the certificate pairs configured for the HTTPS server, the default one first.
+// The development certificate is generated if it is enabled and CertFile is empty
+func (cfg *Config) certPairs() ([]CertPair, error) {
+	pairs := make([]CertPair, 0, len(cfg.Certificates)+1)
+	switch {
+	case cfg.CertFile != "":
+		pairs = append(pairs, CertPair{CertFile: cfg.CertFile, KeyFile: cfg.KeyFile})
+	case cfg.DevCert:
+		files, err := devcert.Ensure(devcert.Options{Dir: cfg.DevCertDir})
+		if err != nil {
+			return nil, err
+		}
+		LOGHANDLER.Info("HTTPS with the development certificate, trust the CA", files.CACertFile)
+		pairs = append(pairs, CertPair{CertFile: files.CertFile, KeyFile: files.KeyFile})
+	}
+	return append(pairs, cfg.Certificates...), nil
+}
+
+// ReloadCertificates reloads the certificates of the running HTTPS server.
+// The pairs which fail to load keep their previous certificate.
+func (router *Router) ReloadCertificates() error {
+	router.serverLock.RLock()
+	certs := router.certs
+	router.serverLock.RUnlock()
+	if certs == nil {
+		return nil
+	}
+	return certs.Reload()
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for the hosts and its key, and returns their paths
+func writeTestCert(t *testing.T, dir string, name string, hosts []string, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     hosts,
+		NotBefore:    notAfter.Add(-time.Hour * 48),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func servedCert(t *testing.T, cr *CertReloader, serverName string) string {
+	t.Helper()
+	cert, err := cr.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestCertReloader_SNI(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	valid := time.Now().Add(time.Hour * 24)
+	aCert, aKey := writeTestCert(t, dir, "a", []string{"a.example.com"}, valid)
+	bCert, bKey := writeTestCert(t, dir, "b", []string{"*.b.example.com"}, valid)
+	cCert, cKey := writeTestCert(t, dir, "c", []string{"ignored.example.com"}, valid)
+
+	cr, err := NewCertReloader(
+		CertPair{CertFile: aCert, KeyFile: aKey},
+		CertPair{CertFile: bCert, KeyFile: bKey},
+		CertPair{CertFile: cCert, KeyFile: cKey, Hosts: []string{"C.example.com"}},
+	)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	tests := []struct {
+		serverName string
+		want       string
+	}{
+		{serverName: "a.example.com", want: "a"},
+		{serverName: "x.b.example.com", want: "b"},
+		{serverName: "x.y.b.example.com", want: "a"},
+		{serverName: "c.example.com.", want: "c"},
+		{serverName: "ignored.example.com", want: "a"},
+		{serverName: "", want: "a"},
+	}
+	for _, tt := range tests {
+		if got := servedCert(t, cr, tt.serverName); got != tt.want {
+			t.Errorf("Expected certificate '%s' for '%s', got '%s'", tt.want, tt.serverName, got)
+		}
+	}
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	valid := time.Now().Add(time.Hour * 24)
+	certFile, keyFile := writeTestCert(t, dir, "old", []string{"example.com"}, valid)
+	cr, err := NewCertReloader(CertPair{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// the unchanged files are not reloaded
+	if err := cr.reload(true); err != nil {
+		t.Errorf("Expected no error, got '%v'", err)
+	}
+
+	newCert, newKey := writeTestCert(t, dir, "new", []string{"example.com"}, valid)
+	copyFile(t, newCert, certFile)
+	copyFile(t, newKey, keyFile)
+	if err := cr.Reload(); err != nil {
+		t.Errorf("Expected no error, got '%v'", err)
+	}
+	if got := servedCert(t, cr, "example.com"); got != "new" {
+		t.Errorf("Expected the new certificate, got '%s'", got)
+	}
+
+	// a certificate which does not match the key is rejected, the previous one is kept
+	badCert, _ := writeTestCert(t, dir, "bad", []string{"example.com"}, valid)
+	copyFile(t, badCert, certFile)
+	if err := cr.Reload(); err == nil {
+		t.Error("Expected an error for a mismatched key, got nil")
+	}
+	if got := servedCert(t, cr, "example.com"); got != "new" {
+		t.Errorf("Expected the previous certificate to be kept, got '%s'", got)
+	}
+
+	expiredCert, expiredKey := writeTestCert(t, dir, "expired", nil, time.Now().Add(-time.Hour))
+	_, err = NewCertReloader(CertPair{CertFile: expiredCert, KeyFile: expiredKey})
+	if !errors.Is(err, ErrCertificateExpired) {
+		t.Errorf("Expected error '%v', got '%v'", ErrCertificateExpired, err)
+	}
+
+	_, err = NewCertReloader()
+	if !errors.Is(err, ErrNoCertificates) {
+		t.Errorf("Expected error '%v', got '%v'", ErrNoCertificates, err)
+	}
+}
+
+func copyFile(t *testing.T, src string, dst string) {
+	t.Helper()
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(dst, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
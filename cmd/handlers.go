@@ -3,27 +3,14 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
 	"net/http"
 	"os"
-	"strings"
 
 	"github.com/pchchv/golog"
 	"github.com/pchchv/web"
 	"github.com/pchchv/web/extensions/sse"
 )
 
-// StaticFilesHandler is used to serve static files
-func StaticFilesHandler(rw http.ResponseWriter, r *http.Request) {
-	wctx := web.Context(r)
-	// '..' is replaced to prevent directory traversal which could go out of static directory
-	path := strings.ReplaceAll(wctx.Params()["w"], "..", "-")
-	path = strings.ReplaceAll(path, "~", "-")
-
-	rw.Header().Set("Last-Modified", lastModified)
-	http.ServeFile(rw, r, fmt.Sprintf("./static/%s", path))
-}
-
 func OriginalResponseWriterHandler(w http.ResponseWriter, r *http.Request) {
 	rw := web.OriginalResponseWriter(w)
 	if rw == nil {
@@ -58,7 +45,7 @@ func SSEHandler(sse *sse.SSE) http.HandlerFunc {
 
 		err := sse.Handler(w, r)
 		if err != nil && !errors.Is(err, context.Canceled) {
-			golog.Info("errorLogger:", err.Error())
+			golog.Info("errorLogger: %s", err.Error())
 			return
 		}
 	}
@@ -76,6 +63,8 @@ func InvalidJSONHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func HomeHandler(w http.ResponseWriter, r *http.Request) {
+	preloadHomepage(w)
+
 	fs, err := os.OpenFile("./static/index.html", os.O_RDONLY, 0600)
 	if err != nil {
 		web.SendError(w, err.Error(), http.StatusInternalServerError)
@@ -93,7 +82,6 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
 		web.SendError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	pushHomepage(r, w)
 
 	_, err = w.Write(out)
 	if err != nil {
@@ -102,51 +90,14 @@ func HomeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func pushCSS(pusher http.Pusher, r *http.Request, path string) {
-	cssOpts := &http.PushOptions{
-		Header: http.Header{
-			"Accept-Encoding": r.Header["Accept-Encoding"],
-			"Content-Type":    []string{"text/css; charset=UTF-8"},
-		},
-	}
-	err := pusher.Push(path, cssOpts)
-	if err != nil {
-		web.LOGHANDLER.Error(err)
-	}
-}
-
-func pushJS(pusher http.Pusher, r *http.Request, path string) {
-	cssOpts := &http.PushOptions{
-		Header: http.Header{
-			"Accept-Encoding": r.Header["Accept-Encoding"],
-			"Content-Type":    []string{"application/javascript"},
-		},
-	}
-	err := pusher.Push(path, cssOpts)
-	if err != nil {
-		web.LOGHANDLER.Error(err)
-	}
-}
-
-func pushHomepage(r *http.Request, w http.ResponseWriter) {
-	pusher, ok := w.(http.Pusher)
-	if !ok {
-		return
-	}
-
-	cp, _ := r.Cookie("pusher")
-	if cp != nil {
-		return
-	}
-
-	cookie := &http.Cookie{
-		Name:   "pusher",
-		Value:  "css,js",
-		MaxAge: 300,
-	}
-	http.SetCookie(w, cookie)
-	pushCSS(pusher, r, "/static/css/main.css")
-	pushCSS(pusher, r, "/static/css/normalize.css")
-	pushJS(pusher, r, "/static/js/main.js")
-	pushJS(pusher, r, "/static/js/sse.js")
+// preloadHomepage sends a 103 Early Hints response, so that the browser
+// starts loading the assets of the homepage before the page itself is sent
+func preloadHomepage(w http.ResponseWriter) {
+	web.EarlyHints(
+		w,
+		web.PreloadLink("/static/css/main.css", "style"),
+		web.PreloadLink("/static/css/normalize.css", "style"),
+		web.PreloadLink("/static/js/main.js", "script"),
+		web.PreloadLink("/static/js/sse.js", "script"),
+	)
 }
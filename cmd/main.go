@@ -15,8 +15,6 @@ import (
 	"github.com/pchchv/web/middleware/cors"
 )
 
-var lastModified = time.Now().Format(http.TimeFormat)
-
 func chain(w http.ResponseWriter, r *http.Request) {
 	r.Header.Set("chained", "true")
 }
@@ -84,13 +82,6 @@ func getRoutes(sse *sse.SSE) []*web.Route {
 			Handlers:      []http.HandlerFunc{OriginalResponseWriterHandler},
 			TrailingSlash: true,
 		},
-		{
-			Name:          "static",
-			Method:        http.MethodGet,
-			Pattern:       "/static/:w*",
-			Handlers:      []http.HandlerFunc{StaticFilesHandler},
-			TrailingSlash: true,
-		},
 		{
 			Name:          "sse",
 			Method:        http.MethodGet,
@@ -112,8 +103,7 @@ func setup() (*web.Router, *sse.SSE) {
 		HTTPSPort:    "9595",
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 1 * time.Hour,
-		CertFile:     "./certs/localhost.crt",
-		KeyFile:      "./certs/localhost.decrypted.key",
+		DevCert:      true,
 	}
 
 	web.GlobalLoggerConfig(
@@ -140,6 +130,13 @@ func setup() (*web.Router, *sse.SSE) {
 
 	routes := getRoutes(sseService)
 	routes = append(routes, routeGroup.Routes()...)
+	routes = append(routes, web.Static("/static", os.DirFS("./static"), &web.StaticOptions{
+		CacheControl: map[string]string{
+			".css": "public, max-age=3600",
+			".js":  "public, max-age=3600",
+		},
+		DefaultCacheControl: "no-cache",
+	})...)
 
 	router := web.NewRouter(cfg, routes...)
 	router.UseOnSpecialHandlers(accesslog.AccessLog)
@@ -171,6 +168,8 @@ func main() {
 		}
 	}()
 
-	go router.StartHTTPS()
-	router.Start()
+	err := router.Run(context.Background())
+	if err != nil {
+		web.LOGHANDLER.Fatal(err)
+	}
 }
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+var (
+	defaultSettings = &responseSettings{
+		envelope:   DefaultEnvelope,
+		codec:      StdJSONCodec{},
+		escapeHTML: true,
+	}
+)
+
+// JSONCodec is the JSON implementation used to encode responses.
+// It allows plugging in a faster encoder than encoding/json.
+type JSONCodec interface {
+	// Marshal returns the JSON encoding of v
+	Marshal(v interface{}) ([]byte, error)
+	// NewEncoder returns a new encoder that writes to w
+	NewEncoder(w io.Writer) JSONStreamEncoder
+}
+
+// JSONStreamEncoder writes JSON values to an output stream
+type JSONStreamEncoder interface {
+	Encode(v interface{}) error
+	// SetEscapeHTML specifies whether problematic HTML characters (<, >, &)
+	// should be escaped inside JSON quoted strings.
+	SetEscapeHTML(on bool)
+}
+
+// StdJSONCodec is the JSONCodec using encoding/json from the standard library
+type StdJSONCodec struct{}
+
+// Marshal returns the JSON encoding of v
+func (StdJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// NewEncoder returns a new json.Encoder that writes to w
+func (StdJSONCodec) NewEncoder(w io.Writer) JSONStreamEncoder {
+	return json.NewEncoder(w)
+}
+
+// ResponseEnvelope wraps the payload of a response before it is encoded.
+// isErr is true for error responses, i.e. the ones sent with SendError.
+// r is nil if the response is not sent through the router.
+type ResponseEnvelope func(r *http.Request, data interface{}, rCode int, isErr bool) interface{}
+
+// DefaultEnvelope wraps the payload in `{data: <payload>, status: <int>}`,
+// or `{errors: <payload>, status: <int>}` for error responses
+func DefaultEnvelope(r *http.Request, data interface{}, rCode int, isErr bool) interface{} {
+	if isErr {
+		return errOutput{Errors: data, Status: rCode}
+	}
+	return dOutput{Data: data, Status: rCode}
+}
+
+// RawEnvelope sends the payload as is, without wrapping it
+func RawEnvelope(r *http.Request, data interface{}, rCode int, isErr bool) interface{} {
+	return data
+}
+
+// responseSettings holds the router-level settings used by the response helpers
+type responseSettings struct {
+	envelope   ResponseEnvelope
+	codec      JSONCodec
+	escapeHTML bool
+	etags      bool
+	request    *http.Request
+}
+
+// codecEncoder is implemented by encoders which use the router's JSONCodec
+type codecEncoder interface {
+	encodeWith(w io.Writer, data interface{}, rs *responseSettings) error
+}
+
+// settings returns the response settings configured on the router
+func (rtr *Router) settings(r *http.Request) *responseSettings {
+	rs := &responseSettings{
+		envelope:   rtr.ResponseEnvelope,
+		codec:      rtr.JSONCodec,
+		escapeHTML: !rtr.DisableHTMLEscape,
+		etags:      rtr.ETags,
+		request:    r,
+	}
+	if rs.envelope == nil {
+		rs.envelope = DefaultEnvelope
+	}
+	if rs.codec == nil {
+		rs.codec = defaultSettings.codec
+	}
+	return rs
+}
+
+// settingsOf returns the response settings of the router which is serving the response
+func settingsOf(w http.ResponseWriter) *responseSettings {
+	crw := findCRW(w)
+	if crw == nil || crw.router == nil {
+		return defaultSettings
+	}
+	return crw.router.settings(crw.request)
+}
+
+func (rs *responseSettings) wrap(data interface{}, rCode int, isErr bool) interface{} {
+	return rs.envelope(rs.request, data, rCode, isErr)
+}
+
+func (rs *responseSettings) encodeJSON(w io.Writer, data interface{}) error {
+	enc := rs.codec.NewEncoder(w)
+	enc.SetEscapeHTML(rs.escapeHTML)
+	return enc.Encode(data)
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type countingCodec struct {
+	StdJSONCodec
+	calls int
+}
+
+func (cc *countingCodec) NewEncoder(w io.Writer) JSONStreamEncoder {
+	cc.calls++
+	return cc.StdJSONCodec.NewEncoder(w)
+}
+
+func envelopeRouter(t *testing.T, handler http.HandlerFunc) *Router {
+	t.Helper()
+	return NewRouter(&Config{}, &Route{
+		Name:          "envelope",
+		Method:        http.MethodGet,
+		Pattern:       "/",
+		TrailingSlash: true,
+		Handlers:      []http.HandlerFunc{handler},
+	})
+}
+
+func TestRouter_ResponseEnvelope(t *testing.T) {
+	t.Parallel()
+	router := envelopeRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		R200(w, "<hello>")
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	router.ServeHTTP(w, r)
+	body, _ := ioutil.ReadAll(w.Body)
+	want := `{"data":"\u003chello\u003e","status":200}` + "\n"
+	if string(body) != want {
+		t.Errorf("Expected '%s', got '%s'", want, string(body))
+	}
+
+	// raw body without HTML escaping
+	router.ResponseEnvelope = RawEnvelope
+	router.DisableHTMLEscape = true
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	body, _ = ioutil.ReadAll(w.Body)
+	want = `"<hello>"` + "\n"
+	if string(body) != want {
+		t.Errorf("Expected '%s', got '%s'", want, string(body))
+	}
+
+	// custom envelope with fields from the request
+	router.ResponseEnvelope = func(r *http.Request, data interface{}, rCode int, isErr bool) interface{} {
+		return map[string]interface{}{
+			"payload":    data,
+			"request_id": r.Header.Get("X-Request-ID"),
+			"error":      isErr,
+		}
+	}
+	w = httptest.NewRecorder()
+	r.Header.Set("X-Request-ID", "abc")
+	router.ServeHTTP(w, r)
+	resp := map[string]interface{}{}
+	err := json.NewDecoder(w.Body).Decode(&resp)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if resp["request_id"] != "abc" || resp["payload"] != "<hello>" || resp["error"] != false {
+		t.Errorf("Unexpected response '%v'", resp)
+	}
+}
+
+func TestRouter_JSONCodec(t *testing.T) {
+	t.Parallel()
+	codec := &countingCodec{}
+	router := envelopeRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		R404(w, "not found")
+	})
+	router.JSONCodec = codec
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	router.ServeHTTP(w, r)
+	if codec.calls != 1 {
+		t.Errorf("Expected codec to be used once, got %d", codec.calls)
+	}
+	body, _ := ioutil.ReadAll(w.Body)
+	want := `{"errors":"not found","status":404}` + "\n"
+	if string(body) != want {
+		t.Errorf("Expected '%s', got '%s'", want, string(body))
+	}
+
+	// negotiated JSON responses use the router's codec as well
+	router = envelopeRouter(t, func(w http.ResponseWriter, r *http.Request) {
+		Respond(w, r, "hello", http.StatusOK)
+	})
+	router.JSONCodec = codec
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if codec.calls != 2 {
+		t.Errorf("Expected codec to be used twice, got %d", codec.calls)
+	}
+}
+
+func TestStdJSONCodec(t *testing.T) {
+	t.Parallel()
+	var codec JSONCodec = StdJSONCodec{}
+	got, err := codec.Marshal(map[string]string{"hello": "<world>"})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	want := `{"hello":"\u003cworld\u003e"}`
+	if string(got) != want {
+		t.Errorf("Expected '%s', got '%s'", want, string(got))
+	}
+
+	_, err = codec.Marshal(make(chan int))
+	if err == nil {
+		t.Error("Expected an error marshaling a channel, got nil")
+	}
+}
+
+func TestSettingsOf(t *testing.T) {
+	t.Parallel()
+	rs := settingsOf(httptest.NewRecorder())
+	if rs != defaultSettings {
+		t.Errorf("Expected default settings outside the router, got %v", rs)
+	}
+
+	if _, ok := rs.wrap("hello", http.StatusOK, false).(dOutput); !ok {
+		t.Error("Expected default envelope to be dOutput")
+	}
+	if _, ok := rs.wrap("hello", http.StatusBadRequest, true).(errOutput); !ok {
+		t.Error("Expected default error envelope to be errOutput")
+	}
+}
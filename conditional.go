@@ -0,0 +1,108 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderIfNoneMatch is a key to refer to the entity tags of the conditional request header
+	HeaderIfNoneMatch = "If-None-Match"
+	// HeaderIfModifiedSince is a key to refer to the modification time of the conditional request header
+	HeaderIfModifiedSince = "If-Modified-Since"
+	// HeaderLastModified is a key to refer to the modification time of the response header
+	HeaderLastModified = "Last-Modified"
+)
+
+// strongETag returns the quoted, strong ETag for the hash sum
+func strongETag(sum []byte) string {
+	if len(sum) > 16 {
+		sum = sum[:16]
+	}
+	return `"` + hex.EncodeToString(sum) + `"`
+}
+
+// contentETag returns the strong ETag of the content
+func contentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return strongETag(sum[:])
+}
+
+// isConditional reports whether the response to the request can be a 304 Not Modified
+func isConditional(r *http.Request, rCode int) bool {
+	if r == nil || rCode != http.StatusOK {
+		return false
+	}
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// etagMatch reports whether the If-None-Match header value matches the ETag, using the weak comparison
+func etagMatch(ifNoneMatch string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified reports whether the resource was not modified, as per the conditional request headers.
+// If-Modified-Since is ignored if If-None-Match is present.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get(HeaderIfNoneMatch); inm != "" {
+		return etag != "" && etagMatch(inm, etag)
+	}
+
+	ims := r.Header.Get(HeaderIfModifiedSince)
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// the HTTP date has a resolution of seconds
+	return !lastModified.Truncate(time.Second).After(t)
+}
+
+// sendNotModified sends a 304 response without a body
+func sendNotModified(w http.ResponseWriter) {
+	header := w.Header()
+	header.Del(HeaderContentType)
+	header.Del(HeaderContentLength)
+	w = crwAsserter(w, http.StatusNotModified)
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// NotModified sets the ETag and Last-Modified headers from the handler-supplied version and modification time,
+// and reports whether the client's cached copy is still fresh. In which case a 304 Not Modified response is sent,
+// and the handler should return without encoding the response.
+// Either of version and lastModified can be empty. e.g.
+//
+//	if web.NotModified(w, r, strconv.Itoa(user.Version), user.UpdatedAt) {
+//		return
+//	}
+//	web.R200(w, user)
+func NotModified(w http.ResponseWriter, r *http.Request, version string, lastModified time.Time) bool {
+	header := w.Header()
+	etag := ""
+	if version != "" {
+		etag = `"` + strings.Trim(version, `"`) + `"`
+		header.Set(HeaderETag, etag)
+	}
+	if !lastModified.IsZero() {
+		header.Set(HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if !isConditional(r, http.StatusOK) || !notModified(r, etag, lastModified) {
+		return false
+	}
+
+	sendNotModified(w)
+	return true
+}
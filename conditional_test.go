@@ -0,0 +1,138 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouter_ETags(t *testing.T) {
+	t.Parallel()
+	encoded := 0
+	router := NewRouter(&Config{}, &Route{
+		Name:    "etag",
+		Method:  http.MethodGet,
+		Pattern: "/etag",
+		Handlers: []http.HandlerFunc{func(w http.ResponseWriter, r *http.Request) {
+			encoded++
+			R200(w, "hello")
+		}},
+	})
+	router.ETags = true
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/etag", nil)
+	router.ServeHTTP(w, r)
+	etag := w.Header().Get(HeaderETag)
+	if w.Code != http.StatusOK || etag == "" {
+		t.Errorf("Expected 200 with ETag, got %d '%s'", w.Code, etag)
+	}
+
+	w = httptest.NewRecorder()
+	r.Header.Set(HeaderIfNoneMatch, `"other", W/`+etag)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 without body, got %d '%s'", w.Code, w.Body.String())
+	}
+	if w.Header().Get(HeaderContentLength) != "" {
+		t.Errorf("Expected no Content-Length, got '%s'", w.Header().Get(HeaderContentLength))
+	}
+
+	w = httptest.NewRecorder()
+	r.Header.Set(HeaderIfNoneMatch, `"other"`)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("Expected 200 with body, got %d", w.Code)
+	}
+
+	// disabled
+	router.ETags = false
+	w = httptest.NewRecorder()
+	r.Header.Set(HeaderIfNoneMatch, etag)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Header().Get(HeaderETag) != "" {
+		t.Errorf("Expected 200 without ETag, got %d '%s'", w.Code, w.Header().Get(HeaderETag))
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	t.Parallel()
+	modified := time.Date(2023, 1, 1, 10, 0, 0, 500, time.UTC)
+	tests := []struct {
+		name         string
+		method       string
+		header       http.Header
+		version      string
+		lastModified time.Time
+		want         bool
+	}{
+		{
+			name:    "no conditional headers",
+			method:  http.MethodGet,
+			header:  http.Header{},
+			version: "v1",
+			want:    false,
+		},
+		{
+			name:    "matching version",
+			method:  http.MethodGet,
+			header:  http.Header{HeaderIfNoneMatch: {`"v1"`}},
+			version: "v1",
+			want:    true,
+		},
+		{
+			name:    "wildcard",
+			method:  http.MethodHead,
+			header:  http.Header{HeaderIfNoneMatch: {`*`}},
+			version: "v1",
+			want:    true,
+		},
+		{
+			name:         "different version ignores If-Modified-Since",
+			method:       http.MethodGet,
+			header:       http.Header{HeaderIfNoneMatch: {`"v0"`}, HeaderIfModifiedSince: {modified.Format(http.TimeFormat)}},
+			version:      "v1",
+			lastModified: modified,
+			want:         false,
+		},
+		{
+			name:         "not modified since",
+			method:       http.MethodGet,
+			header:       http.Header{HeaderIfModifiedSince: {modified.Format(http.TimeFormat)}},
+			lastModified: modified,
+			want:         true,
+		},
+		{
+			name:         "modified since",
+			method:       http.MethodGet,
+			header:       http.Header{HeaderIfModifiedSince: {modified.Add(-time.Hour).Format(http.TimeFormat)}},
+			lastModified: modified,
+			want:         false,
+		},
+		{
+			name:    "unsafe method",
+			method:  http.MethodPost,
+			header:  http.Header{HeaderIfNoneMatch: {`"v1"`}},
+			version: "v1",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.Header = tt.header
+			got := NotModified(w, r, tt.version, tt.lastModified)
+			if got != tt.want {
+				t.Errorf("NotModified() = %v, want %v", got, tt.want)
+			}
+			if got && w.Code != http.StatusNotModified {
+				t.Errorf("Expected response status code %d, got %d", http.StatusNotModified, w.Code)
+			}
+			if tt.version != "" && w.Header().Get(HeaderETag) != `"`+tt.version+`"` {
+				t.Errorf("Expected ETag '\"%s\"', got '%s'", tt.version, w.Header().Get(HeaderETag))
+			}
+		})
+	}
+}
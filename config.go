@@ -1,9 +1,15 @@
 package web
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,17 +26,110 @@ type Config struct {
 	KeyFile string `json:"keyFile,omitempty"`
 	// HTTPSPort is the port number on which the server should listen to HTTP requests
 	HTTPSPort string `json:"httpsPort,omitempty"`
+	// Certificates are additional certificates of the HTTPS server, selected by the server name (SNI).
+	// The certificate of CertFile and KeyFile is the default one
+	Certificates []CertPair `json:"certificates,omitempty"`
+	// CertReloadInterval, if not zero, is the interval at which the certificate files are checked for changes,
+	// and reloaded without restarting the server. Certificates are also reloaded on SIGHUP
+	CertReloadInterval time.Duration `json:"certReloadInterval,omitempty"`
+	// DevCert, if true and CertFile is empty, HTTPS is served with a development certificate for localhost,
+	// signed by a local CA which is generated on first use. See the devcert package
+	DevCert bool `json:"devCert,omitempty"`
+	// DevCertDir is the directory where the development CA and certificate are cached,
+	// the default is devcert.DefaultDir
+	DevCertDir string `json:"devCertDir,omitempty"`
+
+	// Listen is the address of the HTTP server, which overrides Host and Port.
+	// It is either "host:port", or "unix:/path/to/file.sock" for a Unix domain socket
+	Listen string `json:"listen,omitempty"`
+	// ListenHTTPS is the address of the HTTPS server, which overrides Host and HTTPSPort
+	ListenHTTPS string `json:"listenHTTPS,omitempty"`
+	// SocketMode is the file mode of the Unix domain sockets, e.g. 0660 (432 in JSON).
+	// If zero, the mode is set by the umask
+	SocketMode os.FileMode `json:"socketMode,omitempty"`
+	// SocketActivation, if true, the listeners passed by systemd socket activation are used by Run,
+	// instead of listening on the configured addresses. See SystemdListeners
+	SocketActivation bool `json:"socketActivation,omitempty"`
+	// H2C, if true, the HTTP server also serves HTTP/2 cleartext (h2c), with prior knowledge and with
+	// the HTTP/1.1 Upgrade header. It is meant to be used behind a proxy which terminates TLS
+	H2C bool `json:"h2c,omitempty"`
+
+	// RedirectHTTPS, if true, the HTTP server redirects all the requests to the HTTPS server
+	// with 308 Permanent Redirect, except for the paths in RedirectHTTPSAllow
+	RedirectHTTPS bool `json:"redirectHTTPS,omitempty"`
+	// RedirectHTTPSAllow are the paths which are served over HTTP when RedirectHTTPS is enabled, along with
+	// the paths below them, e.g. "/.well-known/acme-challenge/" and health checks
+	RedirectHTTPSAllow []string `json:"redirectHTTPSAllow,omitempty"`
+	// HSTSMaxAge, if not zero, the HTTPS server sets the Strict-Transport-Security header with the max-age
+	HSTSMaxAge time.Duration `json:"hstsMaxAge,omitempty"`
+	// HSTSIncludeSubDomains, if true, the HSTS policy applies to all the subdomains
+	HSTSIncludeSubDomains bool `json:"hstsIncludeSubDomains,omitempty"`
+
+	// GracefulRestart, if true, Run hands the listeners over to a new process on SIGUSR2, and shuts down
+	// once the new process is serving. In-flight requests are completed within ShutdownTimeout (Unix only)
+	GracefulRestart bool `json:"gracefulRestart,omitempty"`
+	// RestartExecutable is the executable started on graceful restart, with the arguments of the current process.
+	// The default is the current executable, as resolved when Run is called
+	RestartExecutable string `json:"restartExecutable,omitempty"`
 
 	// ReadTimeout is the maximum length of time for which the server will read the request
 	ReadTimeout time.Duration `json:"readTimeout,omitempty"`
 	// WriteTimeout is the maximum time for which the server will try to respond to the request
 	WriteTimeout time.Duration `json:"writeTimeout,omitempty"`
+	// ReadHeaderTimeout is the maximum time for which the server will read the request headers,
+	// it protects against clients which send the headers slowly (Slowloris). If zero, ReadTimeout is used
+	ReadHeaderTimeout time.Duration `json:"readHeaderTimeout,omitempty"`
+	// IdleTimeout is the maximum time to wait for the next request on a keep-alive connection.
+	// If zero, ReadTimeout is used
+	IdleTimeout time.Duration `json:"idleTimeout,omitempty"`
+	// MaxHeaderBytes is the maximum size of the request headers, the default is http.DefaultMaxHeaderBytes
+	MaxHeaderBytes int `json:"maxHeaderBytes,omitempty"`
+	// DisableKeepAlives, if true, the connections are closed after each request
+	DisableKeepAlives bool `json:"disableKeepAlives,omitempty"`
+	// TCPKeepAlive is the period of the TCP keep-alive probes of the accepted connections.
+	// If zero, the default period of the net package is used, if negative, the probes are disabled
+	TCPKeepAlive time.Duration `json:"tcpKeepAlive,omitempty"`
 
-	// InsecureSkipVerify is the HTTP certificate verification
+	// ConnState is called when a client connection changes state, see http.Server.ConnState
+	ConnState func(net.Conn, http.ConnState) `json:"-"`
+	// BaseContext returns the base context of the requests accepted on the listener,
+	// see http.Server.BaseContext
+	BaseContext func(net.Listener) context.Context `json:"-"`
+	// ConnContext modifies the context of the requests of a new connection, see http.Server.ConnContext
+	ConnContext func(ctx context.Context, c net.Conn) context.Context `json:"-"`
+
+	// InsecureSkipVerify is the HTTP certificate verification.
+	//
+	// Deprecated: it only applies to TLS clients, and is ignored by the server.
+	// Use ClientAuth and ClientCAFile to verify the client certificates.
 	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
 
+	// TLSMinVersion is the minimum TLS version of the HTTPS server, e.g. "1.2" or "1.3"
+	TLSMinVersion string `json:"tlsMinVersion,omitempty"`
+	// TLSMaxVersion is the maximum TLS version of the HTTPS server
+	TLSMaxVersion string `json:"tlsMaxVersion,omitempty"`
+	// CipherSuites are the enabled TLS 1.0-1.2 cipher suites, by their names as in tls.CipherSuites,
+	// e.g. "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256". The insecure ones are rejected,
+	// and TLS 1.3 cipher suites are not configurable
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+	// CurvePreferences are the elliptic curves used in the key exchange, in order of preference,
+	// e.g. "X25519", "P256", "P384" and "P521"
+	CurvePreferences []string `json:"curvePreferences,omitempty"`
+	// NextProtos are the supported ALPN protocols, in order of preference, e.g. "h2" and "http/1.1"
+	NextProtos []string `json:"nextProtos,omitempty"`
+	// ClientAuth is the client certificate authentication (mutual TLS) of the HTTPS server, one of
+	// "none", "request", "require", "verify-if-given" and "require-and-verify".
+	// The default is "require-and-verify" if ClientCAFile is set, otherwise "none"
+	ClientAuth string `json:"clientAuth,omitempty"`
+	// ClientCAFile is the path to the PEM encoded CA certificates, used to verify the client certificates
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+
 	// ShutdownTimeout is the duration during which the preferential shutdown will be completed
 	ShutdownTimeout time.Duration
+	// ShutdownDelay is the time between the start of the shutdown, when the router reports ShuttingDown
+	// (e.g. failing readiness checks), and the closing of the listeners, so that load balancers stop
+	// sending new requests first
+	ShutdownDelay time.Duration `json:"shutdownDelay,omitempty"`
 
 	// ReverseMiddleware, if true,
 	// will change the execution order of the middleware from the order it was added.
@@ -38,34 +137,168 @@ type Config struct {
 	ReverseMiddleware bool
 }
 
-// Loads config file from the provided filepath and validate
-func (cfg *Config) Load(filepath string) {
-	file, err := ioutil.ReadFile(filepath)
+// Load loads the config file from the provided path, overrides it with the WEB_* environment variables
+// (see LoadEnv) and validates it. The file is JSON, YAML (.yaml, .yml) or TOML (.toml), by its extension,
+// with the same keys in all the formats. Durations are either nanoseconds or strings such as "15s"
+func (cfg *Config) Load(path string) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		LOGHANDLER.Fatal(err)
+	}
+
+	err = cfg.decode(path, file)
 	if err != nil {
 		LOGHANDLER.Fatal(err)
 	}
 
-	err = json.Unmarshal(file, cfg)
+	err = cfg.LoadEnv()
 	if err != nil {
 		LOGHANDLER.Fatal(err)
 	}
 
 	err = cfg.Validate()
 	if err != nil {
-		LOGHANDLER.Fatal(ErrInvalidPort)
+		LOGHANDLER.Fatal(err)
 	}
 }
 
-// Validate the config parsed into the Config struct
+// Validate validates the config parsed into the Config struct: the ports, the host, the certificate
+// and key files, and the timeouts. All the problems are reported at once: a single problem is returned as is,
+// e.g. ErrInvalidPort, several problems are joined with errors.Join
 func (cfg *Config) Validate() error {
-	i, err := strconv.Atoi(cfg.Port)
+	var errs []error
+	// the port is not required if the address is configured
+	if (cfg.Listen == "" || cfg.Port != "") && !validPort(cfg.Port) {
+		errs = append(errs, ErrInvalidPort)
+	}
+	if cfg.HTTPSPort != "" && !validPort(cfg.HTTPSPort) {
+		errs = append(errs, fmt.Errorf("httpsPort: %w", ErrInvalidPort))
+	}
+	if cfg.Host != "" {
+		err := validateHost(cfg.Host)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, cfg.validateFiles()...)
+	errs = append(errs, cfg.validateTimeouts()...)
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// validPort reports whether the port is a number between 1 and 65535
+func validPort(port string) bool {
+	i, err := strconv.Atoi(port)
 	if err != nil {
-		return ErrInvalidPort
+		return false
 	}
+	return i > 0 && i <= 65535
+}
 
-	if i <= 0 || i > 65535 {
-		return ErrInvalidPort
+// validateHost returns an error if the host is neither an IP address nor a valid hostname
+func validateHost(host string) error {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		ip := net.ParseIP(host[1 : len(host)-1])
+		if ip != nil && ip.To4() == nil {
+			return nil
+		}
+		return fmt.Errorf("%w: invalid host '%s'", ErrInvalidConfig, host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() == nil {
+			// the host is joined with the port
+			return fmt.Errorf("%w: IPv6 host '%s' must be in brackets, e.g. '[::1]'", ErrInvalidConfig, host)
+		}
+		return nil
 	}
 
+	name := strings.TrimSuffix(host, ".")
+	valid := len(name) > 0 && len(name) <= 253
+	for _, label := range strings.Split(name, ".") {
+		if !valid {
+			break
+		}
+		valid = len(label) > 0 && len(label) <= 63 && label[0] != '-' && label[len(label)-1] != '-'
+		for _, c := range label {
+			// underscores are not allowed by RFC 1123, but are common e.g. in Docker Compose service names
+			if !(c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+				valid = false
+			}
+		}
+	}
+	if !valid {
+		return fmt.Errorf("%w: invalid host '%s'", ErrInvalidConfig, host)
+	}
 	return nil
 }
+
+// validateFiles returns the errors of the certificate and key files which are configured but do not exist
+func (cfg *Config) validateFiles() []error {
+	var errs []error
+	checkFile := func(key string, file string) {
+		info, err := os.Stat(file)
+		if err == nil && info.IsDir() {
+			err = errors.New("is a directory")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, key, err))
+		}
+	}
+
+	if cfg.CertFile != "" {
+		checkFile("certFile", cfg.CertFile)
+		if cfg.KeyFile == "" {
+			errs = append(errs, ErrNoKeyFile)
+		}
+	}
+	if cfg.KeyFile != "" {
+		checkFile("keyFile", cfg.KeyFile)
+	}
+	for i, pair := range cfg.Certificates {
+		checkFile(fmt.Sprintf("certificates[%d].certFile", i), pair.CertFile)
+		checkFile(fmt.Sprintf("certificates[%d].keyFile", i), pair.KeyFile)
+	}
+	if cfg.ClientCAFile != "" {
+		checkFile("clientCAFile", cfg.ClientCAFile)
+	}
+	return errs
+}
+
+// validateTimeouts returns the errors of the negative or inconsistent timeouts and limits
+func (cfg *Config) validateTimeouts() []error {
+	var errs []error
+	durations := []struct {
+		key string
+		d   time.Duration
+	}{
+		{key: "readTimeout", d: cfg.ReadTimeout},
+		{key: "writeTimeout", d: cfg.WriteTimeout},
+		{key: "readHeaderTimeout", d: cfg.ReadHeaderTimeout},
+		{key: "idleTimeout", d: cfg.IdleTimeout},
+		{key: "ShutdownTimeout", d: cfg.ShutdownTimeout},
+		{key: "shutdownDelay", d: cfg.ShutdownDelay},
+		{key: "certReloadInterval", d: cfg.CertReloadInterval},
+		{key: "hstsMaxAge", d: cfg.HSTSMaxAge},
+	}
+	for _, d := range durations {
+		if d.d < 0 {
+			errs = append(errs, fmt.Errorf("%w: %s must not be negative, got %s", ErrInvalidConfig, d.key, d.d))
+		}
+	}
+	if cfg.ReadTimeout > 0 && cfg.ReadHeaderTimeout > cfg.ReadTimeout {
+		errs = append(errs, fmt.Errorf(
+			"%w: readHeaderTimeout %s is greater than readTimeout %s",
+			ErrInvalidConfig, cfg.ReadHeaderTimeout, cfg.ReadTimeout,
+		))
+	}
+	if cfg.MaxHeaderBytes < 0 {
+		errs = append(errs, fmt.Errorf("%w: maxHeaderBytes must not be negative", ErrInvalidConfig))
+	}
+	return errs
+}
@@ -2,6 +2,11 @@ package web
 
 import (
 	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -34,11 +39,14 @@ func TestConfig_Validate(t *testing.T) {
 		WriteTimeout       time.Duration
 		InsecureSkipVerify bool
 		ShutdownTimeout    time.Duration
+		ReadHeaderTimeout  time.Duration
+		Listen             string
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		wantErr bool
+		name     string
+		fields   fields
+		wantErr  bool
+		wantErrs []error
 	}{
 		{
 			name: "invalid port",
@@ -54,8 +62,56 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid host and HTTPS port",
+			fields: fields{
+				Host:      "[::1]",
+				Port:      "9000",
+				HTTPSPort: "9443",
+			},
+			wantErr: false,
+		},
+		{
+			name: "hostname with underscores",
+			fields: fields{
+				Host: "my_service.internal",
+				Port: "9000",
+			},
+			wantErr: false,
+		},
+		{
+			name: "listen address without port",
+			fields: fields{
+				Listen: "unix:/run/app.sock",
+			},
+			wantErr: false,
+		},
+		{
+			name: "all problems at once",
+			fields: fields{
+				Host:              "::1",
+				Port:              "9000",
+				HTTPSPort:         "70000",
+				CertFile:          "missing.crt",
+				ReadTimeout:       time.Second,
+				ReadHeaderTimeout: time.Second * 2,
+				ShutdownTimeout:   -time.Second,
+			},
+			wantErr:  true,
+			wantErrs: []error{ErrInvalidPort, ErrInvalidConfig, ErrNoKeyFile},
+		},
+		{
+			name: "invalid hostname",
+			fields: fields{
+				Host: "-example.com",
+				Port: "9000",
+			},
+			wantErr:  true,
+			wantErrs: []error{ErrInvalidConfig},
+		},
 	}
 	for _, tt := range tests {
+		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &Config{
 				Host:               tt.fields.Host,
@@ -67,10 +123,18 @@ func TestConfig_Validate(t *testing.T) {
 				WriteTimeout:       tt.fields.WriteTimeout,
 				InsecureSkipVerify: tt.fields.InsecureSkipVerify,
 				ShutdownTimeout:    tt.fields.ShutdownTimeout,
+				ReadHeaderTimeout:  tt.fields.ReadHeaderTimeout,
+				Listen:             tt.fields.Listen,
 			}
-			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
 				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Errorf("Expected error '%v' in '%v'", want, err)
+				}
+			}
 		})
 	}
 }
@@ -84,7 +148,7 @@ func TestConfig_LoadInvalid(t *testing.T) {
 
 	cfg := &Config{}
 	cfg.Load("")
-	str := tl.out.String()
+	str := tl.String()
 	want := "open : no such file or directoryunexpected end of JSON inputPort number not provided or is invalid (should be between 0 - 65535)"
 	got := str
 	if got != want {
@@ -94,5 +158,100 @@ func TestConfig_LoadInvalid(t *testing.T) {
 			got,
 		)
 	}
-	tl.out.Reset()
+	tl.Reset()
+}
+
+func TestConfig_LoadFormats(t *testing.T) {
+	t.Parallel()
+	want := Config{
+		Host:               "127.0.0.1",
+		Port:               "9696",
+		HTTPSPort:          "8443",
+		DevCert:            true,
+		ReadTimeout:        time.Second * 15,
+		WriteTimeout:       time.Minute,
+		RedirectHTTPSAllow: []string{"/.well-known/acme-challenge/", "/healthz"},
+	}
+	for _, file := range []string{"tests/config.json", "tests/config.yaml", "tests/config.toml"} {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := Config{}
+		err = got.decode(file, data)
+		if err != nil {
+			t.Errorf("%s: expected no error, got '%v'", file, err)
+			continue
+		}
+		// only JSON has the deprecated field
+		got.InsecureSkipVerify = false
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %+v, got %+v", file, want, got)
+		}
+	}
+
+	err := (&Config{}).decode("config.json", []byte(`{"readTimeout": "15 seconds"}`))
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected error '%v', got '%v'", ErrInvalidConfig, err)
+	}
+}
+
+func TestConfig_LoadEnv(t *testing.T) {
+	t.Parallel()
+	secret := filepath.Join(t.TempDir(), "key")
+	err := ioutil.WriteFile(secret, []byte("/run/secrets/tls.key\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"WEB_PORT":                "8080",
+		"WEB_LISTEN_HTTPS":        "unix:/run/app/https.sock",
+		"WEB_SOCKET_MODE":         "0660",
+		"WEB_READ_HEADER_TIMEOUT": "5s",
+		"WEB_SHUTDOWN_TIMEOUT":    "30000000000",
+		"WEB_H2C":                 "true",
+		"WEB_NEXT_PROTOS":         "h2, http/1.1",
+		"WEB_CLIENT_CA_FILE":      "/etc/ssl/ca.pem",
+		"WEB_KEY_FILE_FILE":       secret,
+		"WEB_CERTIFICATES":        `[{"certFile": "api.crt", "keyFile": "api.key"}]`,
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	cfg := &Config{Port: "9696", Host: "127.0.0.1"}
+	err = cfg.loadEnv(lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{
+		Host:              "127.0.0.1",
+		Port:              "8080",
+		ListenHTTPS:       "unix:/run/app/https.sock",
+		SocketMode:        0660,
+		ReadHeaderTimeout: time.Second * 5,
+		ShutdownTimeout:   time.Second * 30,
+		H2C:               true,
+		NextProtos:        []string{"h2", "http/1.1"},
+		ClientCAFile:      "/etc/ssl/ca.pem",
+		KeyFile:           "/run/secrets/tls.key",
+		Certificates:      []CertPair{{CertFile: "api.crt", KeyFile: "api.key"}},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Expected %+v, got %+v", want, cfg)
+	}
+
+	env = map[string]string{
+		"WEB_PORT":           "8080",
+		"WEB_IDLE_TIMEOUT":   "forever",
+		"WEB_H2C":            "maybe",
+		"WEB_CERT_FILE_FILE": filepath.Join(t.TempDir(), "missing"),
+	}
+	err = (&Config{}).loadEnv(lookup)
+	for _, name := range []string{"WEB_IDLE_TIMEOUT", "WEB_H2C", "WEB_CERT_FILE_FILE"} {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected an error for %s, got '%v'", name, err)
+		}
+	}
 }
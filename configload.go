@@ -0,0 +1,239 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// envPrefix is the prefix of the environment variables which override the config
+	envPrefix = "WEB_"
+	// envFileSuffix is the suffix of the environment variables which point to a file with the value, e.g. a secret
+	envFileSuffix = "_FILE"
+)
+
+// ErrInvalidConfig is the error returned when the config cannot be decoded or is invalid
+var ErrInvalidConfig = errors.New("invalid config")
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// configField is a field of Config which can be loaded from a file or the environment
+type configField struct {
+	// key is the JSON key of the field
+	key   string
+	index int
+	typ   reflect.Type
+}
+
+// configFields returns the fields of Config which have a JSON key
+func configFields() []configField {
+	t := reflect.TypeOf(Config{})
+	fields := make([]configField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if key == "-" || sf.Type.Kind() == reflect.Func {
+			continue
+		}
+		if key == "" {
+			key = sf.Name
+		}
+		fields = append(fields, configField{key: key, index: i, typ: sf.Type})
+	}
+	return fields
+}
+
+// UnmarshalJSON decodes the config, the durations are either nanoseconds or strings such as "15s" and "1m30s"
+func (cfg *Config) UnmarshalJSON(data []byte) error {
+	type plainConfig Config
+
+	var values map[string]json.RawMessage
+	err := json.Unmarshal(data, &values)
+	if err != nil {
+		return err
+	}
+	for _, f := range configFields() {
+		if f.typ != durationType {
+			continue
+		}
+		for key, value := range values {
+			// the keys are matched case insensitively, as by encoding/json
+			if !strings.EqualFold(key, f.key) || len(value) == 0 || value[0] != '"' {
+				continue
+			}
+			var s string
+			err = json.Unmarshal(value, &s)
+			if err != nil {
+				return err
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, key, err)
+			}
+			values[key] = json.RawMessage(strconv.FormatInt(int64(d), 10))
+		}
+	}
+
+	data, err = json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, (*plainConfig)(cfg))
+}
+
+// decode decodes the config file in the format of its extension, YAML (.yaml, .yml), TOML (.toml) or JSON.
+// The keys are the same in all the formats
+func (cfg *Config) decode(name string, data []byte) error {
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err := yaml.Unmarshal(data, &values)
+		if err != nil {
+			return err
+		}
+	case ".toml":
+		err := toml.Unmarshal(data, &values)
+		if err != nil {
+			return err
+		}
+	default:
+		return json.Unmarshal(data, cfg)
+	}
+
+	if values == nil {
+		return nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, cfg)
+}
+
+// envName returns the environment variable name of the key, in upper snake case, e.g. "WEB_LISTEN_HTTPS" for "listenHTTPS"
+func envName(key string) string {
+	runes := []rune(key)
+	var sb strings.Builder
+	sb.WriteString(envPrefix)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			// an acronym ends before the last upper case letter followed by a lower case one, e.g. "CAFile"
+			endOfAcronym := unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || endOfAcronym {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
+
+// LoadEnv overrides the config with the WEB_* environment variables, named after the keys in upper snake case,
+// e.g. WEB_HTTPS_PORT for "httpsPort" and WEB_SHUTDOWN_TIMEOUT for ShutdownTimeout. If WEB_<NAME>_FILE is set
+// instead, the value is read from that file, e.g. a secret mounted by Docker or Kubernetes.
+// Durations are either nanoseconds or strings such as "15s", lists are comma separated, and integers can be
+// octal, e.g. "0660" for SocketMode. All the invalid variables are reported at once.
+func (cfg *Config) LoadEnv() error {
+	return cfg.loadEnv(os.LookupEnv)
+}
+
+func (cfg *Config) loadEnv(lookup func(string) (string, bool)) error {
+	v := reflect.ValueOf(cfg).Elem()
+	var errs []error
+	for _, f := range configFields() {
+		name := envName(f.key)
+		value, ok := lookup(name)
+		if !ok {
+			file, ok := lookup(name + envFileSuffix)
+			if !ok {
+				continue
+			}
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, name+envFileSuffix, err))
+				continue
+			}
+			name += envFileSuffix
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+
+		err := setField(v.Field(f.index), value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setField sets the field to the value of an environment variable
+func setField(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := parseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			// e.g. the certificate pairs, as JSON
+			return json.Unmarshal([]byte(value), field.Addr().Interface())
+		}
+		list := reflect.MakeSlice(field.Type(), 0, strings.Count(value, ",")+1)
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				list = reflect.Append(list, reflect.ValueOf(item))
+			}
+		}
+		field.Set(list)
+	default:
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	}
+	return nil
+}
+
+// parseDuration parses a duration string such as "15s", or a number of nanoseconds
+func parseDuration(value string) (time.Duration, error) {
+	ns, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return time.Duration(ns), nil
+	}
+	return time.ParseDuration(value)
+}
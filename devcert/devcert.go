@@ -0,0 +1,350 @@
+/*
+The devcert package generates a local certificate authority (CA) and a certificate signed by it,
+for HTTPS during development and in tests, without any private keys checked into the repository.
+The files are cached on disk, and the CA certificate can be added to the trust store of the system
+or the browser, so that the certificate is trusted.
+*/
+package devcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// CACertName is the name of the CA certificate file, within the directory
+	CACertName = "ca.crt"
+	// CAKeyName is the name of the CA private key file
+	CAKeyName = "ca.key"
+	// CertName is the name of the certificate file
+	CertName = "localhost.crt"
+	// KeyName is the name of the certificate private key file
+	KeyName = "localhost.key"
+
+	caValidity = 10 * 365 * 24 * time.Hour
+	// renewBefore is the remaining validity below which the certificate is renewed
+	renewBefore = 30 * 24 * time.Hour
+)
+
+var (
+	// DefaultHosts are the hosts of the certificate, if none are configured
+	DefaultHosts = []string{"localhost", "127.0.0.1", "::1"}
+	// DefaultValidity is the validity of the certificate, if none is configured
+	DefaultValidity = 365 * 24 * time.Hour
+
+	// ErrInvalidCA is the error returned when the cached CA cannot be used
+	ErrInvalidCA = errors.New("invalid development CA")
+
+	// ensureLock serializes the generation of the files within the process
+	ensureLock sync.Mutex
+)
+
+// Options is used to configure the generated certificates
+type Options struct {
+	// Dir is the directory where the files are cached, the default is DefaultDir
+	Dir string
+	// Hosts are the DNS names and IP addresses of the certificate, the default is DefaultHosts
+	Hosts []string
+	// Validity is the validity of the certificate, the default is DefaultValidity
+	Validity time.Duration
+}
+
+// Files are the paths of the generated files
+type Files struct {
+	// CACertFile is the CA certificate, to be added to the trust stores
+	CACertFile string
+	// CertFile is the certificate for the hosts, signed by the CA
+	CertFile string
+	// KeyFile is the private key of the certificate
+	KeyFile string
+}
+
+// DefaultDir returns the default directory of the files, within the user cache directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "web", "devcert"), nil
+}
+
+// Ensure returns the cached files, or generates them if they do not exist, have expired,
+// or the certificate does not cover the hosts. The CA is reused, so that it stays trusted.
+func Ensure(opts Options) (*Files, error) {
+	ensureLock.Lock()
+	defer ensureLock.Unlock()
+
+	if opts.Dir == "" {
+		dir, err := DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+		opts.Dir = dir
+	}
+	if len(opts.Hosts) == 0 {
+		opts.Hosts = DefaultHosts
+	}
+	if opts.Validity <= 0 {
+		opts.Validity = DefaultValidity
+	}
+
+	err := os.MkdirAll(opts.Dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	files := &Files{
+		CACertFile: filepath.Join(opts.Dir, CACertName),
+		CertFile:   filepath.Join(opts.Dir, CertName),
+		KeyFile:    filepath.Join(opts.Dir, KeyName),
+	}
+	caKeyFile := filepath.Join(opts.Dir, CAKeyName)
+
+	ca, caKey, err := loadCA(files.CACertFile, caKeyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		err = checkCAMissing(files.CACertFile, caKeyFile)
+		if err == nil {
+			ca, caKey, err = createCA(files.CACertFile, caKeyFile)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if validCert(files, ca, opts.Hosts) {
+		return files, nil
+	}
+	err = createCert(files, ca, caKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// CertPool returns a pool with the CA certificate, e.g. for the clients in tests
+func (f *Files) CertPool() (*x509.CertPool, error) {
+	caPEM, err := ioutil.ReadFile(f.CACertFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, ErrInvalidCA
+	}
+	return pool, nil
+}
+
+// loadCA loads the CA certificate and its private key
+func loadCA(certFile string, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok || !ca.IsCA || time.Now().After(ca.NotAfter) {
+		return nil, nil, fmt.Errorf("%w: %s, delete it to generate a new one", ErrInvalidCA, certFile)
+	}
+	return ca, key, nil
+}
+
+// checkCAMissing returns nil if neither of the CA files exists, so that a new CA can be generated.
+// If only one of them exists, ErrInvalidCA is returned, so that a trusted CA certificate is not overwritten
+func checkCAMissing(certFile string, keyFile string) error {
+	for _, name := range []string{certFile, keyFile} {
+		_, err := os.Stat(name)
+		if err == nil {
+			return fmt.Errorf("%w: only %s exists, restore the other file or delete it to generate a new CA", ErrInvalidCA, name)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// createCA generates the CA certificate and its private key
+func createCA(certFile string, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"web development CA"},
+			CommonName:   "web development CA " + hostname,
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = writeKey(keyFile, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = writePEM(certFile, "CERTIFICATE", der, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca, key, nil
+}
+
+// validCert reports whether the cached certificate is signed by the CA, covers the hosts
+// and does not expire soon
+func validCert(files *Files, ca *x509.Certificate, hosts []string) bool {
+	pair, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	if err != nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+	if time.Now().Add(renewBefore).After(cert.NotAfter) || cert.CheckSignatureFrom(ca) != nil {
+		return false
+	}
+
+	names := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses))
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return sameHosts(names, hosts)
+}
+
+// sameHosts reports whether the certificate names and the hosts are the same, regardless of the order
+func sameHosts(names []string, hosts []string) bool {
+	if len(names) != len(hosts) {
+		return false
+	}
+	normalized := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			host = ip.String()
+		}
+		normalized = append(normalized, host)
+	}
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	sort.Strings(normalized)
+	for i := range names {
+		if names[i] != normalized[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// createCert generates the certificate for the hosts, signed by the CA
+func createCert(files *Files, ca *x509.Certificate, caKey *ecdsa.PrivateKey, opts Options) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"web development certificate"},
+			CommonName:   opts.Hosts[0],
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(opts.Validity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range opts.Hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		} else {
+			tpl.DNSNames = append(tpl.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	err = writeKey(files.KeyFile, key)
+	if err != nil {
+		return err
+	}
+	return writePEM(files.CertFile, "CERTIFICATE", der, 0644)
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func writeKey(file string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return writePEM(file, "PRIVATE KEY", der, 0600)
+}
+
+// writePEM writes the PEM block to a temporary file, which is renamed to the file,
+// so that a partially written file is never read
+func writePEM(file string, blockType string, der []byte, mode os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = pem.Encode(tmp, &pem.Block{Type: blockType, Bytes: der})
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
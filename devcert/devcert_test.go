@@ -0,0 +1,161 @@
+package devcert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func leafOf(t *testing.T, files *Files) *x509.Certificate {
+	t.Helper()
+	pair, err := tls.LoadX509KeyPair(files.CertFile, files.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func TestEnsure(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	files, err := Ensure(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := files.CertPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf := leafOf(t, files)
+	for _, host := range DefaultHosts {
+		_, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: pool})
+		if err != nil {
+			t.Errorf("Expected the certificate to be valid for '%s', got '%v'", host, err)
+		}
+	}
+
+	info, err := os.Stat(files.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// the cached files are reused
+	cached, err := Ensure(Options{Dir: dir, Hosts: []string{"::1", "localhost", "127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := leafOf(t, cached); !got.Equal(leaf) {
+		t.Error("Expected the cached certificate to be reused")
+	}
+
+	// other hosts renew the certificate, signed by the same CA
+	renewed, err := Ensure(Options{Dir: dir, Hosts: []string{"app.localhost"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := leafOf(t, renewed)
+	if got.Equal(leaf) {
+		t.Error("Expected a new certificate for other hosts")
+	}
+	_, err = got.Verify(x509.VerifyOptions{DNSName: "app.localhost", Roots: pool})
+	if err != nil {
+		t.Errorf("Expected the new certificate to be signed by the CA, got '%v'", err)
+	}
+
+	// a certificate about to expire is renewed
+	hosts := []string{"short.localhost"}
+	expiring, err := Ensure(Options{Dir: dir, Hosts: hosts, Validity: time.Hour * 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	short := leafOf(t, expiring)
+	renewed, err = Ensure(Options{Dir: dir, Hosts: hosts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leafOf(t, renewed).Equal(short) {
+		t.Error("Expected the expiring certificate to be renewed")
+	}
+}
+
+func TestEnsure_InvalidCA(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	files, err := Ensure(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a leaf certificate cannot be used as the CA
+	data, err := ioutil.ReadFile(files.CertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ioutil.ReadFile(files.KeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ioutil.WriteFile(files.CACertFile, data, 0644)
+	_ = ioutil.WriteFile(filepath.Join(dir, CAKeyName), key, 0600)
+
+	_, err = Ensure(Options{Dir: dir})
+	if !errors.Is(err, ErrInvalidCA) {
+		t.Errorf("Expected error '%v', got '%v'", ErrInvalidCA, err)
+	}
+}
+
+func TestEnsure_PartialCA(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		missing string
+	}{
+		{name: "missing key", missing: CAKeyName},
+		{name: "missing certificate", missing: CACertName},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			files, err := Ensure(Options{Dir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			caPEM, err := ioutil.ReadFile(files.CACertFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.Remove(filepath.Join(dir, tt.missing))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Ensure(Options{Dir: dir})
+			if !errors.Is(err, ErrInvalidCA) {
+				t.Errorf("Expected error '%v', got '%v'", ErrInvalidCA, err)
+			}
+			if tt.missing == CACertName {
+				return
+			}
+			got, err := ioutil.ReadFile(files.CACertFile)
+			if err != nil || string(got) != string(caPEM) {
+				t.Errorf("Expected the CA certificate not to be overwritten (%v)", err)
+			}
+		})
+	}
+}
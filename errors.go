@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 )
 
 const (
@@ -23,7 +24,17 @@ const (
 var (
 	// ErrInvalidPort is the error returned when the port number provided in the config file is invalid
 	ErrInvalidPort = errors.New("Port number not provided or is invalid (should be between 0 - 65535)")
-	lh             *logHandler
+	// ErrRouteNotFound is the error returned when there is no route with the given name
+	ErrRouteNotFound = errors.New("route not found")
+	// ErrMissingURIParam is the error returned when a named URI parameter of the route is not provided
+	ErrMissingURIParam = errors.New("missing URI parameter")
+	// ErrInvalidRedirect is the error returned when the redirect URL is invalid or contains control characters
+	ErrInvalidRedirect = errors.New("invalid redirect URL")
+	// ErrNoCertificate is the error returned when HTTPS is started without a certificate file
+	ErrNoCertificate = errors.New("no certificate provided for HTTPS")
+	// ErrNoKeyFile is the error returned when HTTPS is started without a key file
+	ErrNoKeyFile = errors.New("no key file provided for HTTPS")
+	lh           *logHandler
 	// LOGHANDLER is a global variable which web uses to log messages
 	LOGHANDLER Logger
 )
@@ -141,3 +152,22 @@ func GlobalLoggerConfig(stdout io.Writer, stderr io.Writer, cfgs ...logCfg) {
 
 	LOGHANDLER = loggerWithCfg(stdout, stderr, cfgs...)
 }
+
+// serverLogWriter writes the logs of http.Server to LOGHANDLER
+type serverLogWriter struct{}
+
+func (serverLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	if strings.HasPrefix(msg, "http: TLS handshake error") {
+		// handshake errors are caused by the clients, e.g. scanners and untrusted certificates
+		LOGHANDLER.Warn(msg)
+	} else {
+		LOGHANDLER.Error(msg)
+	}
+	return len(p), nil
+}
+
+// serverErrorLog returns the logger of http.Server, which logs to LOGHANDLER
+func serverErrorLog() *log.Logger {
+	return log.New(serverLogWriter{}, "", 0)
+}
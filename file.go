@@ -0,0 +1,165 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HeaderContentDisposition is a key to refer to the content disposition of the response header
+const HeaderContentDisposition = "Content-Disposition"
+
+// ErrUnsupportedFile is the error returned when SendFile is given anything other than a path or an fs.File
+var ErrUnsupportedFile = errors.New("file should be a path or an fs.File")
+
+// FileOptions is used to configure the file responses
+type FileOptions struct {
+	// Name is the file name presented to the client. The default is the base name of the file
+	Name string
+	// Inline if true, the file is displayed by the browser instead of being downloaded as an attachment
+	Inline bool
+	// ContentType is the content type of the file.
+	// If empty, it is detected from the extension of the name, or sniffed from the content
+	ContentType string
+}
+
+// ContentDisposition returns the Content-Disposition header value for the disposition type
+// (i.e. "attachment" or "inline") and the file name. The name is encoded as per RFC 6266 and RFC 5987,
+// with an ASCII fallback for clients which do not support the extended parameter.
+func ContentDisposition(dispType string, filename string) string {
+	if filename == "" {
+		return dispType
+	}
+
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, filename)
+
+	disposition := fmt.Sprintf(`%s; filename="%s"`, dispType, fallback)
+	if fallback == filename {
+		return disposition
+	}
+	return disposition + "; filename*=UTF-8''" + encodeRFC5987(filename)
+}
+
+// encodeRFC5987 percent-encodes all the characters of s, except for the RFC 5987 attr-chars
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+			sb.WriteByte(c)
+		case strings.IndexByte("!#$&+-.^_`|~", c) >= 0:
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(hex[c>>4])
+			sb.WriteByte(hex[c&0x0f])
+		}
+	}
+	return sb.String()
+}
+
+// sendFileError responds with 404 if the file does not exist or is not accessible, otherwise with 500
+func sendFileError(w http.ResponseWriter, err error) {
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		R404(w, ErrNotFound)
+		return
+	}
+	R500(w, ErrInternalServer)
+	LOGHANDLER.Error(err)
+}
+
+// SendFile sends the file, which can be a path on disk or an fs.File (e.g. opened from an embed.FS).
+// Content-Disposition is set to download the file as an attachment, unless opts.Inline is set.
+// Conditional and range requests are supported. An fs.File is not closed by SendFile.
+// IMPORTANT: the path should not be built from user input without sanitizing it.
+func SendFile(w http.ResponseWriter, r *http.Request, file interface{}, opts *FileOptions) {
+	if opts == nil {
+		opts = &FileOptions{}
+	}
+
+	var f fs.File
+	switch v := file.(type) {
+	case string:
+		osFile, err := os.Open(v)
+		if err != nil {
+			sendFileError(w, err)
+			return
+		}
+		defer osFile.Close()
+		f = osFile
+	case fs.File:
+		f = v
+	default:
+		R500(w, ErrInternalServer)
+		LOGHANDLER.Error(fmt.Errorf("%w, got %T", ErrUnsupportedFile, file))
+		return
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		sendFileError(w, err)
+		return
+	}
+	if info.IsDir() {
+		R404(w, ErrNotFound)
+		return
+	}
+
+	content, err := readSeeker(f)
+	if err != nil {
+		sendFileError(w, err)
+		return
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = filepath.Base(info.Name())
+	}
+
+	dispType := "attachment"
+	if opts.Inline {
+		dispType = "inline"
+	}
+	sendContent(w, r, name, dispType, opts.ContentType, info.ModTime(), content)
+}
+
+// SendReader sends the content as an attachment with the given file name.
+// modtime is used for the Last-Modified header and conditional requests, it is ignored if zero.
+// The content type is detected from the extension of the name, or sniffed from the content.
+func SendReader(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, content io.ReadSeeker) {
+	sendContent(w, r, name, "attachment", "", modtime, content)
+}
+
+func sendContent(
+	w http.ResponseWriter,
+	r *http.Request,
+	name string,
+	dispType string,
+	contentType string,
+	modtime time.Time,
+	content io.ReadSeeker,
+) {
+	header := w.Header()
+	header.Set(HeaderContentDisposition, ContentDisposition(dispType, name))
+	if contentType != "" {
+		header.Set(HeaderContentType, contentType)
+	}
+
+	// ServeContent writes the status code (e.g. 206, 304, 416) through the custom response writer,
+	// so that it is reported correctly by ResponseStatus
+	w = crwAsserter(w, http.StatusOK)
+	http.ServeContent(w, r, name, modtime, content)
+}
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestContentDisposition(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		dispType string
+		filename string
+		want     string
+	}{
+		{
+			dispType: "attachment",
+			filename: "report.pdf",
+			want:     `attachment; filename="report.pdf"`,
+		},
+		{
+			dispType: "inline",
+			filename: "",
+			want:     `inline`,
+		},
+		{
+			dispType: "attachment",
+			filename: `naïve "résumé".txt`,
+			want:     `attachment; filename="na_ve _r_sum__.txt"; filename*=UTF-8''na%C3%AFve%20%22r%C3%A9sum%C3%A9%22.txt`,
+		},
+		{
+			dispType: "attachment",
+			filename: "a\r\nb.txt",
+			want:     `attachment; filename="a__b.txt"; filename*=UTF-8''a%0D%0Ab.txt`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			if got := ContentDisposition(tt.dispType, tt.filename); got != tt.want {
+				t.Errorf("ContentDisposition() = '%s', want '%s'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendFile(t *testing.T) {
+	t.Parallel()
+	fpath := filepath.Join(t.TempDir(), "data.csv")
+	err := os.WriteFile(fpath, []byte("a,b\n1,2\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := NewRouter(&Config{}, &Route{
+		Name:    "download",
+		Method:  http.MethodGet,
+		Pattern: "/download",
+		Handlers: []http.HandlerFunc{func(w http.ResponseWriter, r *http.Request) {
+			SendFile(w, r, fpath, &FileOptions{Name: "export.csv"})
+			w.Header().Set("X-Status", http.StatusText(ResponseStatus(w)))
+		}},
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/download", nil)
+	r.Header.Set("Range", "bytes=0-2")
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "a,b" {
+		t.Errorf("Expected 206 'a,b', got %d '%s'", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get(HeaderContentDisposition); got != `attachment; filename="export.csv"` {
+		t.Errorf("Unexpected Content-Disposition '%s'", got)
+	}
+	if !strings.HasPrefix(w.Header().Get(HeaderContentType), "text/csv") {
+		t.Errorf("Expected CSV content type, got '%s'", w.Header().Get(HeaderContentType))
+	}
+
+	// errors are sent like the other error responses
+	errTests := []struct {
+		name string
+		file interface{}
+		code int
+		body string
+	}{
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing"), code: http.StatusNotFound, body: ErrNotFound},
+		{name: "directory", file: t.TempDir(), code: http.StatusNotFound, body: ErrNotFound},
+		{name: "unsupported file type", file: 42, code: http.StatusInternalServerError, body: ErrInternalServer},
+	}
+	for _, tt := range errTests {
+		w = httptest.NewRecorder()
+		SendFile(w, r, tt.file, nil)
+		if w.Code != tt.code || w.Header().Get(HeaderContentType) != JSONContentType {
+			t.Errorf("%s: expected %d JSON response, got %d '%s'", tt.name, tt.code, w.Code, w.Header().Get(HeaderContentType))
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s: expected '%s' in the body, got '%s'", tt.name, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestSendFile_FS(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"logo": &fstest.MapFile{Data: []byte("\x89PNG\r\n\x1a\n0000"), ModTime: time.Now()},
+	}
+	f, err := fsys.Open("logo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	SendFile(w, r, f, &FileOptions{Inline: true})
+	if w.Header().Get(HeaderContentType) != "image/png" {
+		t.Errorf("Expected sniffed content type 'image/png', got '%s'", w.Header().Get(HeaderContentType))
+	}
+	if got := w.Header().Get(HeaderContentDisposition); got != `inline; filename="logo"` {
+		t.Errorf("Unexpected Content-Disposition '%s'", got)
+	}
+}
+
+func TestSendReader(t *testing.T) {
+	t.Parallel()
+	modtime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := newCRW(httptest.NewRecorder(), http.StatusOK)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-Modified-Since", modtime.Format(http.TimeFormat))
+
+	SendReader(w, r, "notes.txt", modtime, strings.NewReader("hello"))
+	if ResponseStatus(w) != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, ResponseStatus(w))
+	}
+}
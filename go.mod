@@ -2,4 +2,11 @@ module github.com/pchchv/web
 
 go 1.20
 
-require github.com/pchchv/golog v1.0.1
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/pchchv/golog v1.0.1
+	golang.org/x/net v0.35.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/text v0.22.0 // indirect
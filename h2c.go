@@ -0,0 +1,22 @@
+package web
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// enableH2C serves HTTP/2 cleartext on the HTTP server, with prior knowledge and with the HTTP/1.1 Upgrade header.
+// The first request of an upgraded connection is read entirely into memory before it is handled.
+func (router *Router) enableH2C(srv *http.Server) {
+	h2s := &http2.Server{}
+	// the HTTP/2 connections are hijacked from the server,
+	// configuring it sends them GOAWAY frames on Shutdown
+	err := http2.ConfigureServer(srv, h2s)
+	if err != nil {
+		LOGHANDLER.Error("h2c is disabled:", err.Error())
+		return
+	}
+	srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+}
@@ -0,0 +1,179 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// serveH2C serves the routes with h2c on a random port, and returns its address
+func serveH2C(t *testing.T, routes ...*Route) string {
+	t.Helper()
+	router := NewRouter(&Config{H2C: true, ShutdownTimeout: time.Second}, routes...)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- router.Serve(l)
+	}()
+	t.Cleanup(func() {
+		_ = router.Shutdown()
+		<-done
+	})
+	return l.Addr().String()
+}
+
+// h2cClient returns a client which sends HTTP/2 requests over cleartext, with prior knowledge
+func h2cClient() *http.Client {
+	return &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+}
+
+func TestH2C_PriorKnowledge(t *testing.T) {
+	t.Parallel()
+	flushed := make(chan struct{})
+	type result struct {
+		proto     string
+		hijackErr error
+		flushed   bool
+	}
+	results := make(chan result, 1)
+	addr := serveH2C(t, &Route{
+		Name:    "stream",
+		Method:  http.MethodGet,
+		Pattern: "/stream",
+		Handlers: []http.HandlerFunc{
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("first\n"))
+				w.(http.Flusher).Flush()
+				// the rest of the response is written once the client received the flushed part
+				<-flushed
+				_, _ = w.Write([]byte("second\n"))
+
+				_, _, err := w.(http.Hijacker).Hijack()
+				info, _ := ResponseInfo(w)
+				results <- result{proto: r.Proto, hijackErr: err, flushed: info.Flushed}
+			},
+		},
+	})
+
+	resp, err := h2cClient().Get("http://" + addr + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body := bufio.NewReader(resp.Body)
+	line, err := body.ReadString('\n')
+	if err != nil || line != "first\n" {
+		t.Errorf("Expected the flushed line, got '%s' (%v)", line, err)
+	}
+	close(flushed)
+	rest, _ := ioutil.ReadAll(body)
+	if string(rest) != "second\n" {
+		t.Errorf("Expected 'second', got '%s'", rest)
+	}
+
+	got := <-results
+	if got.proto != "HTTP/2.0" {
+		t.Errorf("Expected 'HTTP/2.0', got '%s'", got.proto)
+	}
+	if !errors.Is(got.hijackErr, http.ErrNotSupported) {
+		t.Errorf("Expected error '%v', got '%v'", http.ErrNotSupported, got.hijackErr)
+	}
+	if !got.flushed {
+		t.Error("Expected the response to be flushed")
+	}
+}
+
+func TestH2C_Upgrade(t *testing.T) {
+	t.Parallel()
+	addr := serveH2C(t, &Route{
+		Name:    "proto",
+		Method:  http.MethodGet,
+		Pattern: "/proto",
+		Handlers: []http.HandlerFunc{
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("upgraded"))
+			},
+		},
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second * 5))
+
+	_, err = conn.Write([]byte("GET /proto HTTP/1.1\r\nHost: " + addr + "\r\n" +
+		"Connection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: \r\n\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+
+	// the response to the upgraded request is sent on stream 1
+	_, err = conn.Write([]byte(http2.ClientPreface))
+	if err != nil {
+		t.Fatal(err)
+	}
+	framer := http2.NewFramer(conn, br)
+	err = framer.WriteSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var status string
+	var body bytes.Buffer
+	decoder := hpack.NewDecoder(4096, func(f hpack.HeaderField) {
+		if f.Name == ":status" {
+			status = f.Value
+		}
+	})
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.Header().StreamID != 1 {
+			continue
+		}
+		switch f := frame.(type) {
+		case *http2.HeadersFrame:
+			_, _ = decoder.Write(f.HeaderBlockFragment())
+		case *http2.DataFrame:
+			body.Write(f.Data())
+		}
+		if frame.Header().Flags.Has(http2.FlagDataEndStream) {
+			break
+		}
+	}
+	if status != "200" || body.String() != "upgraded" {
+		t.Errorf("Expected status 200 and 'upgraded', got %s and '%s'", status, body.String())
+	}
+}
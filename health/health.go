@@ -0,0 +1,248 @@
+/*
+The health package serves the liveness (/livez) and readiness (/readyz) endpoints of the router, e.g. for
+Kubernetes probes and load balancers. Services add named checks with timeouts, which are run concurrently,
+and the endpoints respond with a JSON report, in the response envelope of the router. Readiness fails as soon
+as the shutdown of the router starts, so that load balancers stop sending requests before the listeners
+are closed (see web.Config.ShutdownDelay).
+*/
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pchchv/web"
+)
+
+const (
+	// LivenessPath is the path of the liveness endpoint
+	LivenessPath = "/livez"
+	// ReadinessPath is the path of the readiness endpoint
+	ReadinessPath = "/readyz"
+
+	// StatusOK is the status of a passing check or report
+	StatusOK = "ok"
+	// StatusFailing is the status of a failing check or report
+	StatusFailing = "failing"
+
+	// DefaultTimeout is the timeout of the checks added without a timeout
+	DefaultTimeout = 5 * time.Second
+)
+
+// ErrShuttingDown is the error of the readiness report once the shutdown of the router started
+var ErrShuttingDown = errors.New("shutting down")
+
+// CheckFunc checks a dependency of the service, e.g. the database, and returns an error if it is not healthy.
+// It should return when ctx is done
+type CheckFunc func(ctx context.Context) error
+
+// check is a named check with its timeout
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// Result is the result of a check
+type Result struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Duration is the time the check took, e.g. "1.2ms"
+	Duration string `json:"duration"`
+}
+
+// Report is the result of all the liveness or readiness checks
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// OK reports whether all the checks passed
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Health has the liveness and readiness checks of a service
+type Health struct {
+	lock      sync.RWMutex
+	liveness  []check
+	readiness []check
+	router    *web.Router
+}
+
+// New returns a Health without any check, the endpoints report ok until checks are added
+func New() *Health {
+	return &Health{}
+}
+
+// AddLivenessCheck adds a check to the liveness endpoint. A failing liveness check means the process
+// should be restarted, e.g. a deadlock, so it should not check external dependencies.
+// If timeout is zero, DefaultTimeout is used
+func (h *Health) AddLivenessCheck(name string, timeout time.Duration, fn CheckFunc) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.liveness = append(h.liveness, newCheck(name, timeout, fn))
+}
+
+// AddReadinessCheck adds a check to the readiness endpoint. A failing readiness check means the service
+// should not receive requests for now, e.g. the database is unreachable.
+// If timeout is zero, DefaultTimeout is used
+func (h *Health) AddReadinessCheck(name string, timeout time.Duration, fn CheckFunc) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.readiness = append(h.readiness, newCheck(name, timeout, fn))
+}
+
+func newCheck(name string, timeout time.Duration, fn CheckFunc) check {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return check{name: name, timeout: timeout, fn: fn}
+}
+
+// Attach ties the readiness to the router, so that Readiness fails once the shutdown of the router starts.
+// ReadinessHandler follows the router serving the request, so Attach is only needed to call Readiness directly.
+// It should be called before the router is started
+func (h *Health) Attach(router *web.Router) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.router = router
+}
+
+// Liveness runs the liveness checks concurrently, and returns their report
+func (h *Health) Liveness(ctx context.Context) Report {
+	h.lock.RLock()
+	checks := h.liveness
+	h.lock.RUnlock()
+	return run(ctx, checks)
+}
+
+// Readiness runs the readiness checks concurrently, and returns their report.
+// Once the shutdown of the attached router started, the checks are not run and the report is failing
+func (h *Health) Readiness(ctx context.Context) Report {
+	return h.readinessOf(ctx, nil)
+}
+
+// readinessOf returns the readiness report, failing once the shutdown of router started.
+// If router is nil, the attached router is used
+func (h *Health) readinessOf(ctx context.Context, router *web.Router) Report {
+	h.lock.RLock()
+	checks := h.readiness
+	if router == nil {
+		router = h.router
+	}
+	h.lock.RUnlock()
+
+	if router != nil && router.ShuttingDown() {
+		return Report{
+			Status: StatusFailing,
+			Checks: []Result{{Name: "shutdown", Status: StatusFailing, Error: ErrShuttingDown.Error(), Duration: "0s"}},
+		}
+	}
+	return run(ctx, checks)
+}
+
+// run runs the checks concurrently, each one within its timeout
+func run(ctx context.Context, checks []check) Report {
+	report := Report{
+		Status: StatusOK,
+		Checks: make([]Result, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			report.Checks[i] = runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	return report
+}
+
+// runCheck runs the check, it returns once the timeout expires even if the check does not
+func runCheck(ctx context.Context, c check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Name:     c.name,
+		Status:   StatusOK,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// serve responds with the report, 200 OK if it passed, otherwise 503 Service Unavailable.
+// The report is sent with the envelope and the JSON codec of the router
+func serve(w http.ResponseWriter, report Report) {
+	w.Header().Set("Cache-Control", "no-store")
+	if !report.OK() {
+		web.SendError(w, report, http.StatusServiceUnavailable)
+		return
+	}
+	web.SendResponse(w, report, http.StatusOK)
+}
+
+// LivenessHandler responds with the liveness report
+func (h *Health) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	serve(w, h.Liveness(r.Context()))
+}
+
+// ReadinessHandler responds with the readiness report, failing once the shutdown of the router
+// serving the request started
+func (h *Health) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	serve(w, h.readinessOf(r.Context(), web.RequestRouter(r)))
+}
+
+// Routes returns the routes of the liveness and readiness endpoints, to be passed to web.NewRouter
+func (h *Health) Routes() []*web.Route {
+	return []*web.Route{
+		{
+			Name:     "health-livez",
+			Method:   http.MethodGet,
+			Pattern:  LivenessPath,
+			Handlers: []http.HandlerFunc{h.LivenessHandler},
+		},
+		{
+			Name:     "health-readyz",
+			Method:   http.MethodGet,
+			Pattern:  ReadinessPath,
+			Handlers: []http.HandlerFunc{h.ReadinessHandler},
+		},
+	}
+}
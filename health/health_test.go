@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pchchv/web"
+)
+
+func TestHealth_Readiness(t *testing.T) {
+	t.Parallel()
+	h := New()
+	slow := func(ctx context.Context) error {
+		time.Sleep(time.Millisecond * 100)
+		return nil
+	}
+	h.AddReadinessCheck("db", 0, slow)
+	h.AddReadinessCheck("cache", 0, slow)
+
+	start := time.Now()
+	report := h.Readiness(context.Background())
+	if !report.OK() || len(report.Checks) != 2 {
+		t.Errorf("Expected 2 passing checks, got %+v", report)
+	}
+	if elapsed := time.Since(start); elapsed > time.Millisecond*190 {
+		t.Errorf("Expected the checks to run concurrently, took %s", elapsed)
+	}
+
+	h.AddReadinessCheck("queue", time.Millisecond*50, func(ctx context.Context) error {
+		// ignores the context
+		time.Sleep(time.Second)
+		return nil
+	})
+	h.AddReadinessCheck("broken", 0, func(ctx context.Context) error {
+		panic("nil map")
+	})
+	report = h.Readiness(context.Background())
+	if report.OK() {
+		t.Error("Expected the report to fail")
+	}
+	want := map[string]string{
+		"db":     "",
+		"cache":  "",
+		"queue":  context.DeadlineExceeded.Error(),
+		"broken": "panic: nil map",
+	}
+	for _, result := range report.Checks {
+		if result.Error != want[result.Name] {
+			t.Errorf("Expected error '%s' for %s, got '%s'", want[result.Name], result.Name, result.Error)
+		}
+	}
+
+	// the liveness checks are separate
+	if report := h.Liveness(context.Background()); !report.OK() || len(report.Checks) != 0 {
+		t.Errorf("Expected no liveness checks, got %+v", report)
+	}
+}
+
+func TestHealth_Routes(t *testing.T) {
+	t.Parallel()
+	h := New()
+	h.AddLivenessCheck("goroutines", 0, func(ctx context.Context) error {
+		return nil
+	})
+	h.AddReadinessCheck("db", 0, func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	router := web.NewRouter(&web.Config{}, h.Routes()...)
+
+	tests := []struct {
+		path   string
+		code   int
+		status string
+	}{
+		{path: LivenessPath, code: http.StatusOK, status: StatusOK},
+		{path: ReadinessPath, code: http.StatusServiceUnavailable, status: StatusFailing},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.code, w.Code)
+		}
+		if ct := w.Header().Get(web.HeaderContentType); ct != web.JSONContentType {
+			t.Errorf("%s: expected content type '%s', got '%s'", tt.path, web.JSONContentType, ct)
+		}
+		body := struct {
+			Data   *Report `json:"data"`
+			Errors *Report `json:"errors"`
+		}{}
+		err := json.Unmarshal(w.Body.Bytes(), &body)
+		report := body.Data
+		if tt.code != http.StatusOK {
+			report = body.Errors
+		}
+		if err != nil || report == nil || report.Status != tt.status || len(report.Checks) != 1 {
+			t.Errorf("%s: expected a report with status '%s', got '%s' (%v)", tt.path, tt.status, w.Body.String(), err)
+		}
+	}
+}
+
+func TestHealth_Shutdown(t *testing.T) {
+	t.Parallel()
+	h := New()
+	router := web.NewRouter(&web.Config{
+		ShutdownTimeout: time.Second,
+		ShutdownDelay:   time.Millisecond * 500,
+	}, h.Routes()...)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- router.Serve(l)
+	}()
+
+	url := "http://" + l.Addr().String() + ReadinessPath
+	get := func() int {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get(); code != http.StatusOK {
+		t.Errorf("Expected status %d before the shutdown, got %d", http.StatusOK, code)
+	}
+
+	go func() {
+		_ = router.Shutdown()
+	}()
+	for !router.ShuttingDown() {
+		time.Sleep(time.Millisecond)
+	}
+	// the listener is still open during the shutdown delay, and the readiness follows the router
+	// serving the request without Attach
+	if code := get(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d once the shutdown started, got %d", http.StatusServiceUnavailable, code)
+	}
+	<-done
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HeaderLink is a key to refer to the link of the response header
+const HeaderLink = "Link"
+
+// PreloadLink returns the Link header value to preload the resource, e.g.
+// PreloadLink("/static/css/main.css", "style") returns `</static/css/main.css>; rel=preload; as=style`
+func PreloadLink(target string, as string) string {
+	link := fmt.Sprintf("<%s>; rel=preload", target)
+	if as != "" {
+		link += "; as=" + as
+	}
+	return link
+}
+
+// EarlyHints sends a 103 Early Hints informational response with the Link headers,
+// so that the client can start preloading resources while the final response is prepared.
+// The links are also kept in the header of the final response, and its status code is not affected.
+// It is the replacement for HTTP/2 server push, which browsers no longer support. e.g.
+//
+//	web.EarlyHints(w, web.PreloadLink("/static/css/main.css", "style"))
+func EarlyHints(w http.ResponseWriter, links ...string) {
+	if len(links) == 0 {
+		return
+	}
+
+	if crw := findCRW(w); crw != nil && crw.headerWritten {
+		LOGHANDLER.Warn("early hints not sent, the response header was already written")
+		return
+	}
+
+	header := w.Header()
+	for _, link := range links {
+		header.Add(HeaderLink, link)
+	}
+	w.WriteHeader(http.StatusEarlyHints)
+}
+
+// DeclareTrailers declares the trailers which will be set after the response body, with SetTrailer.
+// It should be called before the response header is written.
+func DeclareTrailers(w http.ResponseWriter, names ...string) {
+	header := w.Header()
+	for _, name := range names {
+		header.Add(HeaderTrailer, http.CanonicalHeaderKey(name))
+	}
+}
+
+// SetTrailer sets the trailer, which is sent after the response body.
+// Trailers which were not declared with DeclareTrailers are set with http.TrailerPrefix,
+// so that they are sent even if they were not known when the header was written.
+func SetTrailer(w http.ResponseWriter, name string, value string) {
+	header := w.Header()
+	name = http.CanonicalHeaderKey(name)
+	for _, declared := range header.Values(HeaderTrailer) {
+		for _, key := range strings.Split(declared, ",") {
+			if http.CanonicalHeaderKey(strings.TrimSpace(key)) == name {
+				header.Set(name, value)
+				return
+			}
+		}
+	}
+	header.Set(http.TrailerPrefix+name, value)
+}
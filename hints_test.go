@@ -0,0 +1,78 @@
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+)
+
+func TestEarlyHints(t *testing.T) {
+	t.Parallel()
+	var status int
+	router := NewRouter(&Config{}, &Route{
+		Name:    "home",
+		Method:  http.MethodGet,
+		Pattern: "/home",
+		Handlers: []http.HandlerFunc{
+			func(w http.ResponseWriter, r *http.Request) {
+				EarlyHints(w, PreloadLink("/static/main.css", "style"), PreloadLink("/static/main.js", "script"))
+				R201(w, "home")
+				status = ResponseStatus(w)
+			},
+		},
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	var hints []textproto.MIMEHeader
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header)
+			}
+			return nil
+		},
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/home", nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, _ = ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated || status != http.StatusCreated {
+		t.Errorf("Expected final status %d, got %d (ResponseStatus %d)", http.StatusCreated, resp.StatusCode, status)
+	}
+	if len(hints) != 1 {
+		t.Errorf("Expected 1 early hints response, got %d", len(hints))
+		return
+	}
+	want := []string{`</static/main.css>; rel=preload; as=style`, `</static/main.js>; rel=preload; as=script`}
+	got := hints[0].Values(HeaderLink)
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected links %v, got %v", want, got)
+	}
+}
+
+func TestSetTrailer(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	DeclareTrailers(w, "x-checksum")
+	_, _ = w.Write([]byte("hello"))
+	SetTrailer(w, "X-Checksum", "abc")
+	SetTrailer(w, "X-Undeclared", "def")
+
+	trailer := w.Result().Trailer
+	if trailer.Get("X-Checksum") != "abc" {
+		t.Errorf("Expected declared trailer 'abc', got '%s'", trailer.Get("X-Checksum"))
+	}
+	if trailer.Get("X-Undeclared") != "def" {
+		t.Errorf("Expected undeclared trailer 'def', got '%s'", trailer.Get("X-Undeclared"))
+	}
+}
@@ -0,0 +1,138 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+const (
+	// serverHTTP and serverHTTPS identify the servers of the lifecycle
+	serverHTTP  = "http"
+	serverHTTPS = "https"
+)
+
+// LifecycleHook is run when the router starts or shuts down, e.g. to open and close a DB pool.
+// It should return when ctx is done
+type LifecycleHook func(ctx context.Context) error
+
+// RequestHook is called for each request before routing, including the requests answered with 404 and 501
+type RequestHook func(w http.ResponseWriter, r *http.Request)
+
+// ResponseHook is called for each request once it was served, including the requests answered with 404 and 501.
+// ResponseInfo(w) reports the status and the size of the response
+type ResponseHook func(w http.ResponseWriter, r *http.Request)
+
+// lifecycle is the state of the start and shutdown hooks
+type lifecycle struct {
+	lock sync.Mutex
+	// started is set once the start hooks succeeded, until the shutdown hooks are run
+	started bool
+	// active are the servers started since, the shutdown hooks are run once they are all shut down
+	active map[string]bool
+}
+
+// OnStart adds hooks which are run in order before the servers start accepting requests,
+// by Run, Start, StartHTTPS, Serve or ServeTLS. If a hook fails, the startup is aborted and its error
+// is returned, the following hooks and the shutdown hooks are not run.
+// The hooks should be added before the router is started.
+func (router *Router) OnStart(hooks ...LifecycleHook) {
+	router.startHooks = append(router.startHooks, hooks...)
+}
+
+// OnShutdown adds hooks which are run in reverse order once all the servers are shut down, by Run,
+// or by Shutdown and ShutdownHTTPS, i.e. after the in-flight requests are completed.
+// The hooks are run within what is left of ShutdownTimeout, i.e. ctx is the one of the shutdown of the servers,
+// and their errors are returned along with the shutdown errors. If a server fails, e.g. Serve returns an error,
+// the shutdown hooks are run as well, once all the servers are done.
+// The hooks should be added before the router is started.
+func (router *Router) OnShutdown(hooks ...LifecycleHook) {
+	router.shutdownHooks = append(router.shutdownHooks, hooks...)
+}
+
+// OnRequest adds hooks which are called in order for each request, before routing.
+// The hooks should be added before the router is started.
+func (router *Router) OnRequest(hooks ...RequestHook) {
+	router.requestHooks = append(router.requestHooks, hooks...)
+}
+
+// OnResponse adds hooks which are called in order for each request, once it was served.
+// The hooks should be added before the router is started.
+func (router *Router) OnResponse(hooks ...ResponseHook) {
+	router.responseHooks = append(router.responseHooks, hooks...)
+}
+
+// startLifecycle runs the start hooks when the first of the servers starts
+func (router *Router) startLifecycle(ctx context.Context, servers ...string) error {
+	lc := &router.lifecycle
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	if !lc.started {
+		for i, hook := range router.startHooks {
+			err := hook(ctx)
+			if err != nil {
+				return fmt.Errorf("start hook %d: %w", i+1, err)
+			}
+		}
+		lc.started = true
+		lc.active = make(map[string]bool, 2)
+	}
+	for _, srv := range servers {
+		lc.active[srv] = true
+	}
+	return nil
+}
+
+// stopLifecycle runs the shutdown hooks in reverse order, once all the started servers are shut down
+func (router *Router) stopLifecycle(ctx context.Context, servers ...string) error {
+	lc := &router.lifecycle
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	for _, srv := range servers {
+		delete(lc.active, srv)
+	}
+	if !lc.started || len(lc.active) > 0 {
+		return nil
+	}
+	lc.started = false
+
+	var errs []error
+	for i := len(router.shutdownHooks) - 1; i >= 0; i-- {
+		err := router.shutdownHooks[i](ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %d: %w", i+1, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// serveFailed runs the shutdown hooks if the server failed, so that the resources opened by the start hooks
+// are released. It returns err along with the errors of the hooks
+func (router *Router) serveFailed(server string, err error) error {
+	if err == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), router.config.ShutdownTimeout)
+	defer cancel()
+	hookErr := router.stopLifecycle(ctx, server)
+	if hookErr == nil {
+		return err
+	}
+	return errors.Join(err, hookErr)
+}
+
+func (router *Router) runRequestHooks(w http.ResponseWriter, r *http.Request) {
+	for _, hook := range router.requestHooks {
+		hook(w, r)
+	}
+}
+
+func (router *Router) runResponseHooks(w http.ResponseWriter, r *http.Request) {
+	for _, hook := range router.responseHooks {
+		hook(w, r)
+	}
+}
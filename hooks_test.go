@@ -0,0 +1,260 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRouter_LifecycleHooks(t *testing.T) {
+	t.Parallel()
+	router := NewRouter(&Config{
+		Host:            "127.0.0.1",
+		Port:            "0",
+		ShutdownTimeout: time.Second,
+	}, getRoutes(t)...)
+
+	var lock sync.Mutex
+	calls := []string{}
+	hook := func(name string) LifecycleHook {
+		return func(ctx context.Context) error {
+			lock.Lock()
+			defer lock.Unlock()
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	router.OnStart(hook("open db"), hook("open cache"))
+	router.OnShutdown(hook("close db"), hook("close cache"))
+	router.OnShutdown(hook("flush"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- router.Run(ctx)
+	}()
+	time.Sleep(time.Millisecond * 200)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error, got '%v'", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Expected Run to return after the context is cancelled")
+	}
+
+	want := []string{"open db", "open cache", "flush", "close cache", "close db"}
+	lock.Lock()
+	defer lock.Unlock()
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected the hooks to be run as %v, got %v", want, calls)
+	}
+}
+
+func TestRouter_LifecycleHookErrors(t *testing.T) {
+	t.Parallel()
+	errHook := errors.New("connection refused")
+	tests := []struct {
+		name         string
+		start        LifecycleHook
+		shutdown     LifecycleHook
+		wantErr      error
+		wantShutdown bool
+	}{
+		{
+			name:    "start hook fails",
+			start:   func(ctx context.Context) error { return errHook },
+			wantErr: errHook,
+		},
+		{
+			name:  "shutdown hook fails",
+			start: func(ctx context.Context) error { return nil },
+			shutdown: func(ctx context.Context) error {
+				return errHook
+			},
+			wantErr:      errHook,
+			wantShutdown: true,
+		},
+		{
+			name:  "shutdown hook times out",
+			start: func(ctx context.Context) error { return nil },
+			shutdown: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			wantErr:      context.DeadlineExceeded,
+			wantShutdown: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router := NewRouter(&Config{
+				Host:            "127.0.0.1",
+				Port:            "0",
+				ShutdownTimeout: time.Millisecond * 100,
+			}, getRoutes(t)...)
+			shutdown := make(chan struct{}, 2)
+			router.OnStart(tt.start)
+			router.OnShutdown(func(ctx context.Context) error {
+				shutdown <- struct{}{}
+				return nil
+			})
+			if tt.shutdown != nil {
+				router.OnShutdown(tt.shutdown)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+			defer cancel()
+			err := router.Run(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error '%v', got '%v'", tt.wantErr, err)
+			}
+			if ran := len(shutdown) > 0; ran != tt.wantShutdown {
+				t.Errorf("Expected the shutdown hooks to be run: %t, got %t", tt.wantShutdown, ran)
+			}
+		})
+	}
+}
+
+func TestRouter_ShutdownHooksDeadline(t *testing.T) {
+	t.Parallel()
+	started := make(chan struct{})
+	router := NewRouter(&Config{ShutdownTimeout: time.Millisecond * 100}, &Route{
+		Name:    "slow",
+		Method:  http.MethodGet,
+		Pattern: "/slow",
+		Handlers: []http.HandlerFunc{func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(time.Second)
+		}},
+	})
+	hookErr := make(chan error, 1)
+	router.OnShutdown(func(ctx context.Context) error {
+		hookErr <- ctx.Err()
+		return nil
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- router.Serve(l)
+	}()
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	<-started
+
+	// the in-flight request uses up the whole ShutdownTimeout, there is none left for the hooks
+	_ = router.Shutdown()
+	<-done
+	if err := <-hookErr; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the shutdown hooks to share the deadline of the shutdown, got '%v'", err)
+	}
+}
+
+func TestRouter_LifecycleServeErrors(t *testing.T) {
+	t.Parallel()
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	_, port, _ := net.SplitHostPort(busy.Addr().String())
+
+	var lock sync.Mutex
+	calls := []string{}
+	hook := func(name string) LifecycleHook {
+		return func(ctx context.Context) error {
+			lock.Lock()
+			defer lock.Unlock()
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	router := NewRouter(&Config{Host: "127.0.0.1", Port: port, ShutdownTimeout: time.Second}, getRoutes(t)...)
+	router.OnStart(hook("open"))
+	router.OnShutdown(hook("close"))
+
+	// the start hooks are not run if the address is in use
+	router.Start()
+	lock.Lock()
+	if len(calls) != 0 {
+		t.Errorf("Expected no hooks to be run, got %v", calls)
+	}
+	lock.Unlock()
+
+	// the shutdown hooks are run if the server fails
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+	err = router.Serve(l)
+	if err == nil {
+		t.Error("Expected an error serving on a closed listener, got nil")
+	}
+	want := []string{"open", "close"}
+	lock.Lock()
+	defer lock.Unlock()
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected the hooks to be run as %v, got %v", want, calls)
+	}
+}
+
+func TestRouter_RequestHooks(t *testing.T) {
+	t.Parallel()
+	router := NewRouter(&Config{}, getRoutes(t)...)
+
+	var lock sync.Mutex
+	statuses := map[string]int{}
+	router.OnRequest(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Hook", "1")
+	})
+	router.OnResponse(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		info, _ := ResponseInfo(w)
+		statuses[r.Method+" "+r.URL.Path] = info.Status
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{method: http.MethodGet, path: "/", want: http.StatusOK},
+		{method: http.MethodGet, path: "/does-not-exist", want: http.StatusNotFound},
+		{method: "PROPFIND", path: "/", want: http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.want, w.Code)
+		}
+		if w.Header().Get("X-Request-Hook") != "1" {
+			t.Errorf("%s %s: expected the request hook to be called before routing", tt.method, tt.path)
+		}
+		lock.Lock()
+		got := statuses[tt.method+" "+tt.path]
+		lock.Unlock()
+		if got != tt.want {
+			t.Errorf("%s %s: expected the response hook to observe status %d, got %d", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HeaderStrictTransportSecurity is a key to refer to the HSTS policy of the response header
+const HeaderStrictTransportSecurity = "Strict-Transport-Security"
+
+// httpsPort returns the port of the HTTPS server, which is empty if it is the default port
+// or if the HTTPS server is listening on a Unix domain socket
+func (router *Router) httpsPort() string {
+	cfg := router.config
+	port := cfg.HTTPSPort
+	if cfg.ListenHTTPS != "" && !strings.HasPrefix(cfg.ListenHTTPS, unixAddrPrefix) {
+		_, port, _ = net.SplitHostPort(cfg.ListenHTTPS)
+	}
+	if port == "443" || port == "https" {
+		return ""
+	}
+	return port
+}
+
+// httpsRedirectAllowed reports whether the path should be served over HTTP, instead of being redirected.
+// An allowed path matches itself and the paths below it, e.g. "/healthz" matches "/healthz/live" but not "/healthzfoo"
+func (router *Router) httpsRedirectAllowed(path string) bool {
+	for _, allowed := range router.config.RedirectHTTPSAllow {
+		if path == allowed {
+			return true
+		}
+		if !strings.HasSuffix(allowed, "/") {
+			allowed += "/"
+		}
+		if strings.HasPrefix(path, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectHTTPS redirects the request to the HTTPS origin with 308 Permanent Redirect,
+// which keeps the method and the body of the request
+func (router *Router) redirectHTTPS(w http.ResponseWriter, r *http.Request) {
+	if router.httpsRedirectAllowed(r.URL.Path) {
+		router.ServeHTTP(w, r)
+		return
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		host = router.config.Host
+	}
+	if host == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if port := router.httpsPort(); port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// IPv6 address
+		host = "[" + host + "]"
+	}
+
+	w.Header().Set(HeaderLocation, "https://"+host+r.URL.RequestURI())
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+// hsts returns the Strict-Transport-Security header value, which is empty if HSTS is not enabled
+func (router *Router) hsts() string {
+	cfg := router.config
+	if cfg.HSTSMaxAge <= 0 {
+		return ""
+	}
+	value := fmt.Sprintf("max-age=%d", int64(cfg.HSTSMaxAge.Seconds()))
+	if cfg.HSTSIncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	return value
+}
+
+// httpHandler returns the handler of the HTTP server
+func (router *Router) httpHandler() http.Handler {
+	if router.config.RedirectHTTPS {
+		return http.HandlerFunc(router.redirectHTTPS)
+	}
+	return router
+}
+
+// httpsHandler returns the handler of the HTTPS server
+func (router *Router) httpsHandler() http.Handler {
+	hsts := router.hsts()
+	if hsts == "" {
+		return router
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderStrictTransportSecurity, hsts)
+		router.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,126 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouter_RedirectHTTPS(t *testing.T) {
+	t.Parallel()
+	route := &Route{
+		Name:     "health",
+		Method:   http.MethodGet,
+		Pattern:  "/healthz",
+		Handlers: []http.HandlerFunc{dummyHandler},
+	}
+
+	tests := []struct {
+		name         string
+		cfg          *Config
+		method       string
+		target       string
+		host         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{
+			name:         "custom port",
+			cfg:          &Config{RedirectHTTPS: true, HTTPSPort: "8443"},
+			method:       http.MethodPost,
+			target:       "/users?id=1",
+			host:         "example.com:8080",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://example.com:8443/users?id=1",
+		},
+		{
+			name:         "default port",
+			cfg:          &Config{RedirectHTTPS: true, HTTPSPort: "443"},
+			method:       http.MethodGet,
+			target:       "/a%20b",
+			host:         "example.com",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://example.com/a%20b",
+		},
+		{
+			name:         "listen address",
+			cfg:          &Config{RedirectHTTPS: true, ListenHTTPS: "0.0.0.0:9443"},
+			method:       http.MethodGet,
+			target:       "/",
+			host:         "[::1]:8080",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://[::1]:9443/",
+		},
+		{
+			name:       "allowed path",
+			cfg:        &Config{RedirectHTTPS: true, HTTPSPort: "8443", RedirectHTTPSAllow: []string{"/healthz"}},
+			method:     http.MethodGet,
+			target:     "/healthz",
+			host:       "example.com",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "allowed path as a prefix of a segment",
+			cfg:          &Config{RedirectHTTPS: true, HTTPSPort: "8443", RedirectHTTPSAllow: []string{"/healthz"}},
+			method:       http.MethodGet,
+			target:       "/healthz-admin",
+			host:         "example.com",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://example.com:8443/healthz-admin",
+		},
+		{
+			name:       "below an allowed directory",
+			cfg:        &Config{RedirectHTTPS: true, HTTPSPort: "8443", RedirectHTTPSAllow: []string{"/.well-known/acme-challenge/"}},
+			method:     http.MethodGet,
+			target:     "/.well-known/acme-challenge/token",
+			host:       "example.com",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "disabled",
+			cfg:        &Config{HTTPSPort: "8443"},
+			method:     http.MethodGet,
+			target:     "/healthz",
+			host:       "example.com",
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router := NewRouter(tt.cfg, route)
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			router.httpHandler().ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected response status code %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get(HeaderLocation); got != tt.wantLocation {
+				t.Errorf("Expected location '%s', got '%s'", tt.wantLocation, got)
+			}
+		})
+	}
+}
+
+func TestRouter_HSTS(t *testing.T) {
+	t.Parallel()
+	router := NewRouter(&Config{HSTSMaxAge: time.Hour * 24 * 365, HSTSIncludeSubDomains: true})
+	w := httptest.NewRecorder()
+	router.httpsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	want := "max-age=31536000; includeSubDomains"
+	if got := w.Header().Get(HeaderStrictTransportSecurity); got != want {
+		t.Errorf("Expected HSTS header '%s', got '%s'", want, got)
+	}
+
+	router = NewRouter(&Config{})
+	w = httptest.NewRecorder()
+	router.httpsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	if got := w.Header().Get(HeaderStrictTransportSecurity); got != "" {
+		t.Errorf("Expected no HSTS header, got '%s'", got)
+	}
+}
@@ -22,8 +22,6 @@ func setup(port string) (*web.Router, error) {
 		ReadTimeout:     time.Second * 1,
 		WriteTimeout:    time.Second * 1,
 		ShutdownTimeout: time.Second * 10,
-		CertFile:        "tests/ssl/server.crt",
-		KeyFile:         "tests/ssl/server.key",
 	}
 	router := web.NewRouter(cfg, &web.Route{
 		Name:     "hello",
@@ -33,8 +33,6 @@ func setup(port string, routes []*web.Route) (*web.Router, error) {
 		ReadTimeout:     time.Second * 1,
 		WriteTimeout:    time.Second * 1,
 		ShutdownTimeout: time.Second * 10,
-		CertFile:        "tests/ssl/server.crt",
-		KeyFile:         "tests/ssl/server.key",
 	}
 	router := web.NewRouter(cfg, routes...)
 
@@ -0,0 +1,508 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// HeaderAccept is a key to refer to the accepted media types of the request header
+	HeaderAccept = "Accept"
+	// XMLContentType is the MIME type when the response is XML
+	XMLContentType = "application/xml; charset=UTF-8"
+	// CSVContentType is the MIME type when the response is CSV
+	CSVContentType = "text/csv; charset=UTF-8"
+	// TextContentType is the MIME type when the response is plain text
+	TextContentType = "text/plain; charset=UTF-8"
+	// ErrNotAcceptable to send when none of the registered encoders satisfies the Accept header
+	ErrNotAcceptable = "Not acceptable"
+)
+
+var (
+	// ErrCSVUnsupported is the error returned when the CSV encoder is given
+	// anything other than a slice of structs or a [][]string
+	ErrCSVUnsupported = errors.New("CSV encoding is supported only for slices of structs and [][]string")
+	encoders          = newEncoderRegistry()
+)
+
+// Encoder serializes response payloads for a single media type
+type Encoder interface {
+	// ContentType returns the value set as the Content-Type response header
+	ContentType() string
+	// Encode writes the serialized form of data to w
+	Encode(w io.Writer, data interface{}) error
+}
+
+// encoderRegistry holds the registered encoders in registration order,
+// which is used to break ties between equally acceptable media types.
+type encoderRegistry struct {
+	lock       sync.RWMutex
+	mediaTypes []string
+	encoders   map[string]Encoder
+}
+
+// mediaRange is a single, parsed entry of an Accept header
+type mediaRange struct {
+	mainType string
+	subType  string
+	q        float64
+}
+
+// JSONEncoder encodes responses as JSON
+type JSONEncoder struct{}
+
+// ContentType returns the JSON MIME type
+func (JSONEncoder) ContentType() string {
+	return JSONContentType
+}
+
+// Encode writes data to w as JSON
+func (je JSONEncoder) Encode(w io.Writer, data interface{}) error {
+	return je.encodeWith(w, data, defaultSettings)
+}
+
+// encodeWith writes data to w using the JSONCodec configured on the router
+func (JSONEncoder) encodeWith(w io.Writer, data interface{}, rs *responseSettings) error {
+	return rs.encodeJSON(w, data)
+}
+
+// XMLEncoder encodes responses as XML
+type XMLEncoder struct{}
+
+// ContentType returns the XML MIME type
+func (XMLEncoder) ContentType() string {
+	return XMLContentType
+}
+
+// Encode writes data to w as XML.
+// Maps with string keys are encoded as elements named after the keys, sorted by key.
+func (XMLEncoder) Encode(w io.Writer, data interface{}) error {
+	enc := xml.NewEncoder(w)
+	switch out := data.(type) {
+	case dOutput:
+		out.Data = xmlValue(out.Data)
+		return enc.Encode(out)
+	case errOutput:
+		out.Errors = xmlValue(out.Errors)
+		return enc.Encode(out)
+	}
+
+	value := xmlValue(data)
+	if _, ok := value.(xmlMap); ok {
+		return enc.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: "response"}})
+	}
+	return enc.Encode(value)
+}
+
+// xmlMap encodes a map with string keys, which encoding/xml does not support
+type xmlMap struct {
+	value reflect.Value
+}
+
+// MarshalXML encodes each entry of the map as an element named after its key
+func (xm xmlMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := xm.value.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		name := key.String()
+		if !validXMLName(name) {
+			return fmt.Errorf("xml: invalid element name %q", name)
+		}
+		err = e.EncodeElement(
+			xmlValue(xm.value.MapIndex(key).Interface()),
+			xml.StartElement{Name: xml.Name{Local: name}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xmlValue returns v with its maps with string keys, including the ones in slices, converted to xmlMap
+func xmlValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			return xmlMap{value: rv}
+		}
+	case reflect.Slice, reflect.Array:
+		kind := rv.Type().Elem().Kind()
+		if kind != reflect.Map && kind != reflect.Interface {
+			return v
+		}
+		values := make([]interface{}, rv.Len())
+		for i := range values {
+			values[i] = xmlValue(rv.Index(i).Interface())
+		}
+		return values
+	}
+	return v
+}
+
+// validXMLName reports whether name can be used as an element name
+func validXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// CSVEncoder encodes slices of structs as CSV, with the field names
+// (or the `csv` struct tag) as the header row.
+// Only the payload is written, the response envelope has no place in a CSV document.
+type CSVEncoder struct{}
+
+// ContentType returns the CSV MIME type
+func (CSVEncoder) ContentType() string {
+	return CSVContentType
+}
+
+// Encode writes data to w as CSV
+func (CSVEncoder) Encode(w io.Writer, data interface{}) error {
+	records, err := csvRecords(payload(data))
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	err = cw.WriteAll(records)
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// TextEncoder encodes responses as plain text using the default fmt formatting.
+// Only the payload is written, without the response envelope.
+type TextEncoder struct{}
+
+// ContentType returns the plain text MIME type
+func (TextEncoder) ContentType() string {
+	return TextContentType
+}
+
+// Encode writes data to w as plain text
+func (TextEncoder) Encode(w io.Writer, data interface{}) error {
+	_, err := fmt.Fprint(w, payload(data))
+	return err
+}
+
+func newEncoderRegistry() *encoderRegistry {
+	er := &encoderRegistry{
+		encoders: map[string]Encoder{},
+	}
+	// the first registered encoder is the default one, used when the client accepts anything
+	er.register("application/json", JSONEncoder{})
+	er.register("application/xml", XMLEncoder{})
+	er.register("text/xml", XMLEncoder{})
+	er.register("text/csv", CSVEncoder{})
+	er.register("text/plain", TextEncoder{})
+	return er
+}
+
+func (er *encoderRegistry) register(mediaType string, enc Encoder) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	er.lock.Lock()
+	defer er.lock.Unlock()
+
+	if _, ok := er.encoders[mediaType]; !ok {
+		er.mediaTypes = append(er.mediaTypes, mediaType)
+	}
+	er.encoders[mediaType] = enc
+}
+
+// negotiate returns the encoder best matching the Accept header value,
+// nil is returned if none of the registered media types are acceptable.
+func (er *encoderRegistry) negotiate(accept string) Encoder {
+	acceptable := er.acceptable(accept)
+	if len(acceptable) == 0 {
+		return nil
+	}
+	return acceptable[0]
+}
+
+// acceptable returns the encoders of all the acceptable media types, the best matching first
+func (er *encoderRegistry) acceptable(accept string) []Encoder {
+	er.lock.RLock()
+	defer er.lock.RUnlock()
+
+	if strings.TrimSpace(accept) == "" {
+		acceptable := make([]Encoder, 0, len(er.mediaTypes))
+		for _, mt := range er.mediaTypes {
+			acceptable = append(acceptable, er.encoders[mt])
+		}
+		return acceptable
+	}
+
+	// each media type is ranked by its own q-value, from the most specific range matching it,
+	// e.g. text/xml has q=0.1 with "text/*;q=0.9, text/xml;q=0.1"
+	type candidate struct {
+		enc         Encoder
+		q           float64
+		specificity int
+	}
+	ranges := parseAccept(accept)
+	candidates := make([]candidate, 0, len(er.mediaTypes))
+	for _, mt := range er.mediaTypes {
+		q, specificity, ok := quality(ranges, mt)
+		if !ok || q <= 0 {
+			continue
+		}
+		candidates = append(candidates, candidate{enc: er.encoders[mt], q: q, specificity: specificity})
+	}
+	// the registration order breaks the remaining ties
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].q != candidates[j].q {
+			return candidates[i].q > candidates[j].q
+		}
+		return candidates[i].specificity > candidates[j].specificity
+	})
+
+	acceptable := make([]Encoder, 0, len(candidates))
+	for _, c := range candidates {
+		acceptable = append(acceptable, c.enc)
+	}
+	return acceptable
+}
+
+func (mr mediaRange) matches(mediaType string) bool {
+	mainType, subType, _ := strings.Cut(mediaType, "/")
+	if mr.mainType != "*" && mr.mainType != mainType {
+		return false
+	}
+	return mr.subType == "*" || mr.subType == subType
+}
+
+// specificity returns 2 for "type/subtype", 1 for "type/*" and 0 for "*/*"
+func (mr mediaRange) specificity() int {
+	if mr.mainType == "*" {
+		return 0
+	}
+	if mr.subType == "*" {
+		return 1
+	}
+	return 2
+}
+
+// quality returns the q-value of the media type from the most specific range matching it,
+// along with the specificity of that range. ok is false if no range matches the media type
+func quality(ranges []mediaRange, mediaType string) (q float64, specificity int, ok bool) {
+	specificity = -1
+	for _, mr := range ranges {
+		if !mr.matches(mediaType) || mr.specificity() <= specificity {
+			continue
+		}
+		specificity = mr.specificity()
+		q = mr.q
+	}
+	return q, specificity, specificity >= 0
+}
+
+// parseAccept parses the Accept header value and returns the media ranges,
+// sorted by preference i.e. q-value and then specificity.
+func parseAccept(accept string) []mediaRange {
+	parts := strings.Split(accept, ",")
+	ranges := make([]mediaRange, 0, len(parts))
+	for _, part := range parts {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		mainType, subType, ok := strings.Cut(mediaType, "/")
+		if !ok || mainType == "" || subType == "" || (mainType == "*" && subType != "*") {
+			continue
+		}
+
+		mr := mediaRange{
+			mainType: mainType,
+			subType:  subType,
+			q:        1,
+		}
+
+		for _, param := range fields[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(strings.ToLower(key)) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			mr.q = q
+		}
+
+		ranges = append(ranges, mr)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	return ranges
+}
+
+// payload returns the data wrapped inside the response envelope, if any
+func payload(data interface{}) interface{} {
+	switch out := data.(type) {
+	case dOutput:
+		return out.Data
+	case errOutput:
+		return out.Errors
+	}
+	return data
+}
+
+// csvRecords converts a [][]string or a slice of structs to CSV records
+func csvRecords(data interface{}) ([][]string, error) {
+	if records, ok := data.([][]string); ok {
+		return records, nil
+	}
+
+	rv := reflect.ValueOf(data)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, ErrCSVUnsupported
+	}
+
+	elemType := rv.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, ErrCSVUnsupported
+	}
+
+	header := make([]string, 0, elemType.NumField())
+	fields := make([]int, 0, elemType.NumField())
+	for i := 0; i < elemType.NumField(); i++ {
+		field := elemType.Field(i)
+		if field.PkgPath != "" {
+			// unexported field
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("csv"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		header = append(header, name)
+		fields = append(fields, i)
+	}
+
+	records := make([][]string, 0, rv.Len()+1)
+	records = append(records, header)
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				continue
+			}
+			elem = elem.Elem()
+		}
+
+		record := make([]string, len(fields))
+		for j, fieldIdx := range fields {
+			record[j] = fmt.Sprint(elem.Field(fieldIdx).Interface())
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// RegisterEncoder registers enc as the Encoder for the given media type, e.g. "application/json".
+// Registering a media type again replaces its encoder.
+// IMPORTANT: Encoders should be registered before the server is started.
+func RegisterEncoder(mediaType string, enc Encoder) {
+	encoders.register(mediaType, enc)
+}
+
+// NegotiateEncoder returns the registered Encoder best matching the Accept header of the request.
+// It returns nil if none of the registered media types are acceptable.
+func NegotiateEncoder(r *http.Request) Encoder {
+	return encoders.negotiate(r.Header.Get(HeaderAccept))
+}
+
+// Respond is used to respond to any request based on the code, data etc.,
+// with the encoder picked by the request's Accept header.
+// The data is wrapped with the router's ResponseEnvelope the same way as in SendResponse,
+// or as in SendError if rCode is 400 or above.
+// If the picked encoder does not support the data, e.g. an error message as CSV,
+// the next acceptable encoders are tried, keeping rCode. If none of them can encode the data,
+// a 500 response is sent. If none of the registered media types are acceptable, a 406 response is sent.
+func Respond(w http.ResponseWriter, r *http.Request, data interface{}, rCode int) {
+	// the response depends on the Accept header, so caches must not serve it to other clients
+	w.Header().Add(HeaderVary, HeaderAccept)
+
+	acceptable := encoders.acceptable(r.Header.Get(HeaderAccept))
+	if len(acceptable) == 0 {
+		R406(w, ErrNotAcceptable)
+		return
+	}
+
+	rs := settingsOf(w)
+	out := rs.wrap(data, rCode, rCode >= http.StatusBadRequest)
+
+	buf := newBuffer()
+	defer releaseBuffer(buf)
+
+	var err error
+	jsonAccepted := false
+	for _, enc := range acceptable {
+		jsonAccepted = jsonAccepted || enc.ContentType() == JSONContentType
+		buf.Reset()
+		if ce, ok := enc.(codecEncoder); ok {
+			err = ce.encodeWith(buf, out, rs)
+		} else {
+			err = enc.Encode(buf, out)
+		}
+		if err == nil {
+			writeEncoded(w, rs, rCode, enc.ContentType(), buf)
+			return
+		}
+	}
+	if jsonAccepted {
+		sendEncodingError(w, err)
+		return
+	}
+	// the client does not accept JSON, so the error is not sent as JSON either
+	Send(w, TextContentType, ErrInternalServer, http.StatusInternalServerError)
+	LOGHANDLER.Error(err)
+}
@@ -0,0 +1,286 @@
+package web
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type csvRow struct {
+	Name    string `csv:"name"`
+	Age     int
+	Skipped string `csv:"-"`
+	hidden  string
+}
+
+func TestNegotiateEncoder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		accept string
+		want   Encoder
+	}{
+		{
+			name:   "no accept header",
+			accept: "",
+			want:   JSONEncoder{},
+		},
+		{
+			name:   "accept anything",
+			accept: "*/*",
+			want:   JSONEncoder{},
+		},
+		{
+			name:   "exact match",
+			accept: "text/csv",
+			want:   CSVEncoder{},
+		},
+		{
+			name:   "q-value preference",
+			accept: "application/json;q=0.5, application/xml;q=0.9",
+			want:   XMLEncoder{},
+		},
+		{
+			name:   "specificity wins on equal q-value",
+			accept: "text/*, text/plain",
+			want:   TextEncoder{},
+		},
+		{
+			name:   "wildcard subtype",
+			accept: "text/*",
+			want:   XMLEncoder{},
+		},
+		{
+			name:   "explicit exclusion",
+			accept: "*/*, application/json;q=0",
+			want:   XMLEncoder{},
+		},
+		{
+			name:   "browser accept header",
+			accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			want:   XMLEncoder{},
+		},
+		{
+			name:   "own q-value of a type matched by a wildcard",
+			accept: "text/*;q=0.9, text/xml;q=0.1",
+			want:   CSVEncoder{},
+		},
+		{
+			name:   "nothing acceptable",
+			accept: "image/png, application/json;q=0",
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				r.Header.Set(HeaderAccept, tt.accept)
+			}
+			got := NegotiateEncoder(r)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NegotiateEncoder() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespond(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderAccept, "application/json")
+	payload := map[string]string{"hello": "world"}
+
+	Respond(w, r, payload, http.StatusOK)
+	body, _ := ioutil.ReadAll(w.Body)
+
+	resp := struct {
+		Data   map[string]string
+		Status int
+	}{}
+	err := json.Unmarshal(body, &resp)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !reflect.DeepEqual(payload, resp.Data) || resp.Status != http.StatusOK {
+		t.Errorf("Expected '%v', got '%v'. Raw response: '%s'", payload, resp.Data, string(body))
+	}
+	if ct := w.Result().Header.Values(HeaderContentType); len(ct) != 1 || ct[0] != JSONContentType {
+		t.Errorf("Expected content type '%s', got '%v'", JSONContentType, ct)
+	}
+
+	// XML with error envelope
+	w = httptest.NewRecorder()
+	r.Header.Set(HeaderAccept, "application/xml")
+	Respond(w, r, "oops", http.StatusBadRequest)
+	body, _ = ioutil.ReadAll(w.Body)
+	want := `<response><errors>oops</errors><status>400</status></response>`
+	if string(body) != want {
+		t.Errorf("Expected '%s', got '%s'", want, string(body))
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected response status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// CSV
+	w = httptest.NewRecorder()
+	r.Header.Set(HeaderAccept, "text/csv")
+	Respond(w, r, []*csvRow{{Name: "a", Age: 1, Skipped: "x"}, nil, {Name: "b,c", Age: 2}}, http.StatusOK)
+	body, _ = ioutil.ReadAll(w.Body)
+	want = "name,Age\na,1\n\"b,c\",2\n"
+	if string(body) != want {
+		t.Errorf("Expected '%s', got '%s'", want, string(body))
+	}
+	if w.Result().Header.Get(HeaderContentType) != CSVContentType {
+		t.Errorf("Expected content type '%s', got '%s'", CSVContentType, w.Result().Header.Get(HeaderContentType))
+	}
+
+	// CSV with unsupported payload, and no other acceptable media type
+	w = httptest.NewRecorder()
+	Respond(w, r, "hello", http.StatusOK)
+	if w.Code != http.StatusInternalServerError || w.Result().Header.Get(HeaderContentType) == JSONContentType {
+		t.Errorf("Expected a non-JSON response with status code %d, got %d '%s'", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	if vary := w.Result().Header.Values(HeaderVary); len(vary) != 1 || vary[0] != HeaderAccept {
+		t.Errorf("Expected Vary '%s', got '%v'", HeaderAccept, vary)
+	}
+
+	// plain text
+	w = httptest.NewRecorder()
+	r.Header.Set(HeaderAccept, "text/plain")
+	Respond(w, r, "hello world", http.StatusOK)
+	body, _ = ioutil.ReadAll(w.Body)
+	if string(body) != "hello world" {
+		t.Errorf("Expected 'hello world', got '%s'", string(body))
+	}
+
+	// not acceptable
+	w = httptest.NewRecorder()
+	r.Header.Set(HeaderAccept, "image/png")
+	Respond(w, r, "hello world", http.StatusOK)
+	body, _ = ioutil.ReadAll(w.Body)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected response status code %d, got %d", http.StatusNotAcceptable, w.Code)
+	}
+	if !strings.Contains(string(body), ErrNotAcceptable) {
+		t.Errorf("Expected '%s' in body, got '%s'", ErrNotAcceptable, string(body))
+	}
+}
+
+func TestRespond_Fallback(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		accept      string
+		data        interface{}
+		rCode       int
+		contentType string
+		want        string
+	}{
+		{
+			name:        "error as CSV, then JSON",
+			accept:      "text/csv, application/json;q=0.5",
+			data:        "bad",
+			rCode:       http.StatusBadRequest,
+			contentType: JSONContentType,
+			want:        `{"errors":"bad","status":400}` + "\n",
+		},
+		{
+			name:        "error as CSV only",
+			accept:      "text/csv",
+			data:        "bad",
+			rCode:       http.StatusInternalServerError,
+			contentType: TextContentType,
+			want:        ErrInternalServer,
+		},
+		{
+			name:        "error as CSV, JSON excluded",
+			accept:      "text/csv, application/json;q=0",
+			data:        "bad",
+			rCode:       http.StatusInternalServerError,
+			contentType: TextContentType,
+			want:        ErrInternalServer,
+		},
+		{
+			name:        "error as CSV, then plain text",
+			accept:      "text/csv, text/plain;q=0.5",
+			data:        "bad",
+			rCode:       http.StatusBadRequest,
+			contentType: TextContentType,
+			want:        "bad",
+		},
+		{
+			name:        "map as XML",
+			accept:      "application/xml",
+			data:        map[string]interface{}{"name": "web", "tags": map[string]int{"b": 2, "a": 1}},
+			rCode:       http.StatusOK,
+			contentType: XMLContentType,
+			want:        "<response><data><name>web</name><tags><a>1</a><b>2</b></tags></data><status>200</status></response>",
+		},
+		{
+			name:        "map error as XML",
+			accept:      "text/xml",
+			data:        map[string]string{"field": "required"},
+			rCode:       http.StatusUnprocessableEntity,
+			contentType: XMLContentType,
+			want:        "<response><errors><field>required</field></errors><status>422</status></response>",
+		},
+		{
+			name:        "map with invalid XML names",
+			accept:      "application/xml, application/json;q=0.5",
+			data:        map[string]string{"a b": "c"},
+			rCode:       http.StatusCreated,
+			contentType: JSONContentType,
+			want:        `{"data":{"a b":"c"},"status":201}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set(HeaderAccept, tt.accept)
+
+			Respond(w, r, tt.data, tt.rCode)
+			if w.Code != tt.rCode {
+				t.Errorf("Expected response status code %d, got %d", tt.rCode, w.Code)
+			}
+			if ct := w.Result().Header.Get(HeaderContentType); ct != tt.contentType {
+				t.Errorf("Expected content type '%s', got '%s'", tt.contentType, ct)
+			}
+			if w.Body.String() != tt.want {
+				t.Errorf("Expected '%s', got '%s'", tt.want, w.Body.String())
+			}
+			if w.Result().Header.Get(HeaderVary) != HeaderAccept {
+				t.Errorf("Expected Vary '%s', got '%s'", HeaderAccept, w.Result().Header.Get(HeaderVary))
+			}
+		})
+	}
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	t.Parallel()
+	er := newEncoderRegistry()
+	er.register("Application/Vnd.Custom+JSON", JSONEncoder{})
+
+	got := er.negotiate("application/vnd.custom+json")
+	if !reflect.DeepEqual(got, JSONEncoder{}) {
+		t.Errorf("Expected JSONEncoder, got %T", got)
+	}
+
+	er.register("text/plain", JSONEncoder{})
+	if len(er.mediaTypes) != 6 {
+		t.Errorf("Expected 6 media types, got %d", len(er.mediaTypes))
+	}
+	got = er.negotiate("text/plain")
+	if !reflect.DeepEqual(got, JSONEncoder{}) {
+		t.Errorf("Expected JSONEncoder, got %T", got)
+	}
+}
@@ -0,0 +1,89 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// HeaderLocation is a key to refer to the redirect location of the response header
+const HeaderLocation = "Location"
+
+// isRedirectCode reports whether rCode is one of the redirect status codes supported by Redirect
+func isRedirectCode(rCode int) bool {
+	switch rCode {
+	case http.StatusMovedPermanently,
+		http.StatusFound,
+		http.StatusSeeOther,
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// redirectLocation validates target and resolves it against the request URL, if it is relative
+func redirectLocation(r *http.Request, target string) (string, error) {
+	for i := 0; i < len(target); i++ {
+		// control characters (e.g. CR, LF) would allow injecting headers into the response
+		if target[i] < 0x20 || target[i] == 0x7f {
+			return "", fmt.Errorf("%w: %q", ErrInvalidRedirect, target)
+		}
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidRedirect, err.Error())
+	}
+
+	if u.IsAbs() || u.Host != "" {
+		return u.String(), nil
+	}
+
+	base := &url.URL{Path: "/"}
+	if r.URL != nil && r.URL.Path != "" {
+		base.Path = r.URL.Path
+	}
+	return base.ResolveReference(u).String(), nil
+}
+
+// Redirect replies to the request with a redirect to target, setting the Location header.
+// Relative targets are resolved against the request URL.
+// rCode should be one of 301, 302, 303, 307 or 308, any other code is replaced with 302.
+func Redirect(w http.ResponseWriter, r *http.Request, target string, rCode int) {
+	if !isRedirectCode(rCode) {
+		LOGHANDLER.Warn(fmt.Sprintf("Invalid redirect status code %d, using %d", rCode, http.StatusFound))
+		rCode = http.StatusFound
+	}
+
+	location, err := redirectLocation(r, target)
+	if err != nil {
+		R500(w, ErrInternalServer)
+		LOGHANDLER.Error(err)
+		return
+	}
+
+	w = crwAsserter(w, rCode)
+	http.Redirect(w, r, location, rCode)
+}
+
+// RedirectToRoute replies to the request with a 302 redirect to the route with the given name.
+// The named URI parameters of the route are replaced by the values in params.
+// It works only for requests served by the router.
+func RedirectToRoute(w http.ResponseWriter, r *http.Request, name string, params map[string]string) {
+	cp, ok := contextPayload(r)
+	if !ok || cp.router == nil {
+		R500(w, ErrInternalServer)
+		LOGHANDLER.Error(fmt.Errorf("%w: '%s', request is not served by the router", ErrRouteNotFound, name))
+		return
+	}
+
+	target, err := cp.router.URL(name, params)
+	if err != nil {
+		R500(w, ErrInternalServer)
+		LOGHANDLER.Error(err)
+		return
+	}
+
+	Redirect(w, r, target, http.StatusFound)
+}
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		path     string
+		target   string
+		code     int
+		wantCode int
+		wantLoc  string
+	}{
+		{
+			name:     "absolute path",
+			path:     "/a/b",
+			target:   "/login",
+			code:     http.StatusSeeOther,
+			wantCode: http.StatusSeeOther,
+			wantLoc:  "/login",
+		},
+		{
+			name:     "relative path",
+			path:     "/a/b",
+			target:   "c?x=1",
+			code:     http.StatusMovedPermanently,
+			wantCode: http.StatusMovedPermanently,
+			wantLoc:  "/a/c?x=1",
+		},
+		{
+			name:     "parent path",
+			path:     "/a/b/",
+			target:   "../c",
+			code:     http.StatusTemporaryRedirect,
+			wantCode: http.StatusTemporaryRedirect,
+			wantLoc:  "/a/c",
+		},
+		{
+			name:     "absolute URL",
+			path:     "/",
+			target:   "https://example.com/x",
+			code:     http.StatusPermanentRedirect,
+			wantCode: http.StatusPermanentRedirect,
+			wantLoc:  "https://example.com/x",
+		},
+		{
+			name:     "invalid code",
+			path:     "/",
+			target:   "/x",
+			code:     http.StatusOK,
+			wantCode: http.StatusFound,
+			wantLoc:  "/x",
+		},
+		{
+			name:     "header injection",
+			path:     "/",
+			target:   "/x\r\nSet-Cookie: a=b",
+			code:     http.StatusFound,
+			wantCode: http.StatusInternalServerError,
+			wantLoc:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			Redirect(w, r, tt.target, tt.code)
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected response status code %d, got %d", tt.wantCode, w.Code)
+			}
+			if got := w.Header().Get(HeaderLocation); got != tt.wantLoc {
+				t.Errorf("Expected location '%s', got '%s'", tt.wantLoc, got)
+			}
+			if w.Header().Get("Set-Cookie") != "" {
+				t.Error("Unexpected Set-Cookie header")
+			}
+		})
+	}
+}
+
+func TestRedirectToRoute(t *testing.T) {
+	t.Parallel()
+	router := NewRouter(
+		&Config{},
+		&Route{
+			Name:     "user",
+			Method:   http.MethodGet,
+			Pattern:  "/users/:userID/files/:path*",
+			Handlers: []http.HandlerFunc{dummyHandler},
+		},
+		&Route{
+			Name:    "old",
+			Method:  http.MethodGet,
+			Pattern: "/old",
+			Handlers: []http.HandlerFunc{
+				func(w http.ResponseWriter, r *http.Request) {
+					RedirectToRoute(w, r, "user", map[string]string{"userID": "a b", "path": "x/y"})
+				},
+			},
+		},
+	)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/old", nil)
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusFound {
+		t.Errorf("Expected response status code %d, got %d", http.StatusFound, w.Code)
+	}
+	want := "/users/a%20b/files/x/y"
+	if got := w.Header().Get(HeaderLocation); got != want {
+		t.Errorf("Expected location '%s', got '%s'", want, got)
+	}
+
+	// outside the router
+	w = httptest.NewRecorder()
+	RedirectToRoute(w, r, "user", nil)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected response status code %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestRouter_URL(t *testing.T) {
+	t.Parallel()
+	router := NewRouter(&Config{}, &Route{
+		Name:     "user",
+		Method:   http.MethodGet,
+		Pattern:  "/users/:userID",
+		Handlers: []http.HandlerFunc{dummyHandler},
+	})
+
+	got, err := router.URL("user", map[string]string{"userID": "42"})
+	if err != nil || got != "/users/42" {
+		t.Errorf("Expected '/users/42', got '%s' (%v)", got, err)
+	}
+
+	_, err = router.URL("user", nil)
+	if !errors.Is(err, ErrMissingURIParam) {
+		t.Errorf("Expected error '%v', got '%v'", ErrMissingURIParam, err)
+	}
+
+	_, err = router.URL("unknown", nil)
+	if !errors.Is(err, ErrRouteNotFound) {
+		t.Errorf("Expected error '%v', got '%v'", ErrRouteNotFound, err)
+	}
+}
@@ -1,10 +1,15 @@
 package web
 
 import (
-	"encoding/json"
+	"bytes"
+	"encoding/xml"
 	"fmt"
+	"html/template"
+	"io"
 	"net/http"
-	"text/template"
+	"strconv"
+	"sync"
+	"time"
 )
 
 const (
@@ -14,10 +19,29 @@ const (
 	JSONContentType = "application/json"
 	// HTMLContentType is the MIME type when the response is HTML
 	HTMLContentType = "text/html; charset=UTF-8"
+	// HeaderContentLength is a key to refer to the content length of the response header
+	HeaderContentLength = "Content-Length"
+	// HeaderWWWAuthenticate is a key to refer to the authentication challenge of the response header
+	HeaderWWWAuthenticate = "WWW-Authenticate"
+	// HeaderRetryAfter is a key to refer to the retry delay of the response header
+	HeaderRetryAfter = "Retry-After"
 	// ErrInternalServer to send when an internal server error
 	ErrInternalServer = "Internal server error"
+	// ErrNotFound to send when the requested resource does not exist
+	ErrNotFound = "Not found"
+	// maxPooledBufferSize is the capacity above which response buffers are not returned to the pool
+	maxPooledBufferSize = 64 << 10
 )
 
+// internalErrorBody is the body of the 500 response sent when the response cannot be encoded
+var internalErrorBody = []byte(`{"errors":"` + ErrInternalServer + `","status":500}` + "\n")
+
+var bufPool = &sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
 // ErrorData used to render the error page
 type ErrorData struct {
 	ErrCode        int
@@ -26,23 +50,28 @@ type ErrorData struct {
 
 // dOutput is the standard/valid output wrapped in `{data: <payload>, status: <http response status>}`
 type dOutput struct {
-	Data   interface{} `json:"data"`
-	Status int         `json:"status"`
+	XMLName xml.Name    `json:"-" xml:"response"`
+	Data    interface{} `json:"data" xml:"data"`
+	Status  int         `json:"status" xml:"status"`
 }
 
 // errOutput is the error output wrapped in `{errors:<errors>, status: <http response status>}`
 type errOutput struct {
-	Errors interface{} `json:"errors"`
-	Status int         `json:"status"`
+	XMLName xml.Name    `json:"-" xml:"response"`
+	Errors  interface{} `json:"errors" xml:"errors"`
+	Status  int         `json:"status" xml:"status"`
 }
 
 func crwAsserter(w http.ResponseWriter, rCode int) http.ResponseWriter {
-	if crw, ok := w.(*customResponseWriter); ok {
-		crw.statusCode = rCode
-		return crw
+	crw := findCRW(w)
+	if crw == nil {
+		return newCRW(w, rCode)
 	}
 
-	return newCRW(w, rCode)
+	crw.statusCode = rCode
+	// if the custom response writer is wrapped, e.g. by a gzip middleware,
+	// the response should still be written through the wrapper
+	return w
 }
 
 // Send sends a completely custom response without wrapping it in `{data: <data>, status: <int>` struct
@@ -59,53 +88,113 @@ func Send(w http.ResponseWriter, contentType string, data interface{}, rCode int
 
 // SendResponse is used to respond to any request (JSON response) based on the code, data etc.
 func SendResponse(w http.ResponseWriter, data interface{}, rCode int) {
-	w = crwAsserter(w, rCode)
-	w.Header().Add(HeaderContentType, JSONContentType)
-	err := json.NewEncoder(w).Encode(dOutput{Data: data, Status: rCode})
-	if err != nil {
-		/*
-			In case of encoding error, send "internal server error" and
-			log the actual error.
-		*/
-		R500(w, ErrInternalServer)
-		LOGHANDLER.Error(err)
-	}
+	sendJSON(w, data, rCode, false)
 }
 
 // SendError is used to respond to any request with an error
 func SendError(w http.ResponseWriter, data interface{}, rCode int) {
+	sendJSON(w, data, rCode, true)
+}
+
+// sendJSON wraps data in the response envelope and sends it as JSON,
+// with the codec configured on the router
+func sendJSON(w http.ResponseWriter, data interface{}, rCode int, isErr bool) {
+	rs := settingsOf(w)
+	sendEncoded(w, rs, rCode, JSONContentType, func(buf io.Writer) error {
+		return rs.encodeJSON(buf, rs.wrap(data, rCode, isErr))
+	})
+}
+
+// sendEncoded encodes the response body into a pooled buffer before writing anything,
+// so that an encoding error results in a clean 500 response instead of a partial body
+func sendEncoded(w http.ResponseWriter, rs *responseSettings, rCode int, contentType string, encode func(io.Writer) error) {
+	buf := newBuffer()
+	defer releaseBuffer(buf)
+
+	err := encode(buf)
+	if err != nil {
+		sendEncodingError(w, err)
+		return
+	}
+	writeEncoded(w, rs, rCode, contentType, buf)
+}
+
+// writeEncoded sends the encoded response body, Content-Length is set.
+// If ETags are enabled on the router, the ETag is computed from the buffer and 304 is sent if it matches.
+func writeEncoded(w http.ResponseWriter, rs *responseSettings, rCode int, contentType string, buf *bytes.Buffer) {
+	if rs.etags && isConditional(rs.request, rCode) {
+		header := w.Header()
+		etag := header.Get(HeaderETag)
+		if etag == "" {
+			etag = contentETag(buf.Bytes())
+			header.Set(HeaderETag, etag)
+		}
+		if notModified(rs.request, etag, time.Time{}) {
+			sendNotModified(w)
+			return
+		}
+	}
+
+	writeBuffer(w, rCode, contentType, buf)
+}
+
+// sendEncodingError sends "internal server error" and logs the actual error.
+// The body is pre-encoded, the codec and the envelope which failed are not used again.
+func sendEncodingError(w http.ResponseWriter, err error) {
+	LOGHANDLER.Error(err)
+	writeBuffer(w, http.StatusInternalServerError, JSONContentType, bytes.NewBuffer(internalErrorBody))
+}
+
+// writeBuffer sends the buffer as the response body, along with its content type and length
+func writeBuffer(w http.ResponseWriter, rCode int, contentType string, buf *bytes.Buffer) {
 	w = crwAsserter(w, rCode)
-	w.Header().Add(HeaderContentType, JSONContentType)
-	err := json.NewEncoder(w).Encode(errOutput{data, rCode})
+	header := w.Header()
+	header.Set(HeaderContentType, contentType)
+	header.Set(HeaderContentLength, strconv.Itoa(buf.Len()))
+	w.WriteHeader(rCode)
+	_, err := w.Write(buf.Bytes())
 	if err != nil {
-		/*
-			In case of encoding error, send "internal server error" and
-			log the actual error.
-		*/
-		R500(w, ErrInternalServer)
 		LOGHANDLER.Error(err)
 	}
 }
 
+func newBuffer() *bytes.Buffer {
+	return bufPool.Get().(*bytes.Buffer)
+}
+
+// releaseBuffer returns the buffer to the pool, unless it has grown too large,
+// so that a single huge payload does not keep its memory alive in the pool
+func releaseBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufPool.Put(buf)
+}
+
 // SendHeader is used to send only a response header, i.e no response body
 func SendHeader(w http.ResponseWriter, rCode int) {
 	w.WriteHeader(rCode)
 }
 
-// Render is used for rendering templates (HTML)
+// Render is used for rendering templates (HTML).
+// html/template escapes the data contextually, so user supplied strings are safe to render.
+// The template is executed into a buffer first, so an execution error results in a clean 500 response.
 func Render(w http.ResponseWriter, data interface{}, rCode int, tpl *template.Template) {
-	w = crwAsserter(w, rCode)
-
-	// In the case of the HTML response,
-	// setting the appropriate header type for the text/HTML response
-	w.Header().Set(HeaderContentType, HTMLContentType)
+	buf := newBuffer()
+	defer releaseBuffer(buf)
 
 	// Rendering an HTML template with relevant data
-	err := tpl.Execute(w, data)
+	err := tpl.Execute(buf, data)
 	if err != nil {
 		Send(w, "text/plain", ErrInternalServer, http.StatusInternalServerError)
 		LOGHANDLER.Error(err.Error())
+		return
 	}
+
+	// In the case of the HTML response,
+	// setting the appropriate header type for the text/HTML response
+	writeBuffer(w, rCode, HTMLContentType, buf)
 }
 
 // R200 - Successful/OK response
@@ -113,17 +202,23 @@ func R200(w http.ResponseWriter, data interface{}) {
 	SendResponse(w, data, http.StatusOK)
 }
 
-// R201 - New item created
-func R201(w http.ResponseWriter, data interface{}) {
+// R201 - New item created. If location is provided, it is set as the Location header,
+// e.g. the URI of the new item
+func R201(w http.ResponseWriter, data interface{}, location ...string) {
+	if len(location) > 0 && location[0] != "" {
+		w.Header().Set(HeaderLocation, location[0])
+	}
 	SendResponse(w, data, http.StatusCreated)
 }
 
 // R204 - empty, no content
 func R204(w http.ResponseWriter) {
+	w = crwAsserter(w, http.StatusNoContent)
 	SendHeader(w, http.StatusNoContent)
 }
 
-// R302 - Temporary redirect
+// R302 - Temporary redirect, with the data as JSON body and without a Location header.
+// It is meant for API clients which expect the JSON body, use Redirect for browsers.
 func R302(w http.ResponseWriter, data interface{}) {
 	SendResponse(w, data, http.StatusFound)
 }
@@ -133,7 +228,17 @@ func R400(w http.ResponseWriter, data interface{}) {
 	SendError(w, data, http.StatusBadRequest)
 }
 
-// R403 - Unauthorized access
+// R401 - Unauthenticated, the request lacks valid credentials.
+// challenge is set as the WWW-Authenticate header, e.g. `Bearer realm="api"`, the default is "Bearer"
+func R401(w http.ResponseWriter, data interface{}, challenge string) {
+	if challenge == "" {
+		challenge = "Bearer"
+	}
+	w.Header().Set(HeaderWWWAuthenticate, challenge)
+	SendError(w, data, http.StatusUnauthorized)
+}
+
+// R403 - Forbidden, the client is authenticated but not allowed to access the resource
 func R403(w http.ResponseWriter, data interface{}) {
 	SendError(w, data, http.StatusForbidden)
 }
@@ -148,6 +253,37 @@ func R406(w http.ResponseWriter, data interface{}) {
 	SendError(w, data, http.StatusNotAcceptable)
 }
 
+// R409 - Conflict with the current state of the resource, e.g. a duplicate or an edit conflict
+func R409(w http.ResponseWriter, data interface{}) {
+	SendError(w, data, http.StatusConflict)
+}
+
+// R410 - Resource is gone permanently
+func R410(w http.ResponseWriter, data interface{}) {
+	SendError(w, data, http.StatusGone)
+}
+
+// R413 - Request body is larger than the server is willing to process
+func R413(w http.ResponseWriter, data interface{}) {
+	SendError(w, data, http.StatusRequestEntityTooLarge)
+}
+
+// R415 - Unsupported media type of the request body
+func R415(w http.ResponseWriter, data interface{}) {
+	SendError(w, data, http.StatusUnsupportedMediaType)
+}
+
+// R422 - Unprocessable entity, the request body is well-formed but has semantic errors e.g. validation errors
+func R422(w http.ResponseWriter, data interface{}) {
+	SendError(w, data, http.StatusUnprocessableEntity)
+}
+
+// R429 - Too many requests. retryAfter, if not zero, is set as the Retry-After header in seconds
+func R429(w http.ResponseWriter, data interface{}, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	SendError(w, data, http.StatusTooManyRequests)
+}
+
 // R451 - Resource taken down because of a legal request
 func R451(w http.ResponseWriter, data interface{}) {
 	SendError(w, data, http.StatusUnavailableForLegalReasons)
@@ -157,3 +293,19 @@ func R451(w http.ResponseWriter, data interface{}) {
 func R500(w http.ResponseWriter, data interface{}) {
 	SendError(w, data, http.StatusInternalServerError)
 }
+
+// R503 - Service unavailable, e.g. overloaded or under maintenance.
+// retryAfter, if not zero, is set as the Retry-After header in seconds
+func R503(w http.ResponseWriter, data interface{}, retryAfter time.Duration) {
+	setRetryAfter(w, retryAfter)
+	SendError(w, data, http.StatusServiceUnavailable)
+}
+
+// setRetryAfter sets the Retry-After header, rounding the duration up to whole seconds
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		return
+	}
+	seconds := (retryAfter + time.Second - 1) / time.Second
+	w.Header().Set(HeaderRetryAfter, strconv.FormatInt(int64(seconds), 10))
+}
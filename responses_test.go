@@ -2,12 +2,16 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
 	"testing"
-	"text/template"
+	"time"
 )
 
 func TestSend(t *testing.T) {
@@ -579,3 +583,240 @@ func TestRender(t *testing.T) {
 		)
 	}
 }
+
+type partialEncoder struct {
+	w io.Writer
+}
+
+func (pe partialEncoder) Encode(v interface{}) error {
+	_, _ = pe.w.Write([]byte(`{"data":`))
+	return errors.New("encoding failed midway")
+}
+
+func (pe partialEncoder) SetEscapeHTML(on bool) {}
+
+type partialCodec struct {
+	StdJSONCodec
+	fail bool
+}
+
+func (pc *partialCodec) NewEncoder(w io.Writer) JSONStreamEncoder {
+	if !pc.fail {
+		return pc.StdJSONCodec.NewEncoder(w)
+	}
+	// only the first encoding fails, so that the next response succeeds
+	pc.fail = false
+	return partialEncoder{w: w}
+}
+
+func TestSendResponse_BufferedEncoding(t *testing.T) {
+	t.Parallel()
+	router := NewRouter(&Config{}, &Route{
+		Name:     "partial",
+		Method:   http.MethodGet,
+		Pattern:  "/partial",
+		Handlers: []http.HandlerFunc{func(w http.ResponseWriter, r *http.Request) { R200(w, "hello") }},
+	})
+	router.JSONCodec = &partialCodec{fail: true}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/partial", nil)
+	router.ServeHTTP(w, r)
+
+	body, _ := ioutil.ReadAll(w.Body)
+	want := `{"errors":"Internal server error","status":500}` + "\n"
+	if string(body) != want {
+		t.Errorf("Expected '%s', got '%s'", want, string(body))
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected response status code %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if w.Header().Get(HeaderContentLength) != strconv.Itoa(len(want)) {
+		t.Errorf("Expected content length %d, got '%s'", len(want), w.Header().Get(HeaderContentLength))
+	}
+
+	// successful response
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	body, _ = ioutil.ReadAll(w.Body)
+	want = `{"data":"hello","status":200}` + "\n"
+	if string(body) != want {
+		t.Errorf("Expected '%s', got '%s'", want, string(body))
+	}
+	if w.Header().Get(HeaderContentLength) != strconv.Itoa(len(want)) {
+		t.Errorf("Expected content length %d, got '%s'", len(want), w.Header().Get(HeaderContentLength))
+	}
+}
+
+type failingCodec struct{}
+
+func (failingCodec) Marshal(v interface{}) ([]byte, error) {
+	return nil, errors.New("encoding failed")
+}
+
+func (failingCodec) NewEncoder(w io.Writer) JSONStreamEncoder {
+	return partialEncoder{w: w}
+}
+
+func TestSendResponse_EncodingFailure(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		codec    JSONCodec
+		envelope ResponseEnvelope
+	}{
+		{
+			name:  "codec always fails",
+			codec: failingCodec{},
+		},
+		{
+			name: "envelope returns an unencodable value",
+			envelope: func(r *http.Request, data interface{}, rCode int, isErr bool) interface{} {
+				return make(chan int)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router := NewRouter(&Config{}, &Route{
+				Name:    "failing",
+				Method:  http.MethodGet,
+				Pattern: "/failing",
+				Handlers: []http.HandlerFunc{func(w http.ResponseWriter, r *http.Request) {
+					R400(w, "bad request")
+				}},
+			})
+			router.JSONCodec = tt.codec
+			router.ResponseEnvelope = tt.envelope
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/failing", nil))
+			want := `{"errors":"Internal server error","status":500}` + "\n"
+			if w.Body.String() != want {
+				t.Errorf("Expected '%s', got '%s'", want, w.Body.String())
+			}
+			if w.Code != http.StatusInternalServerError {
+				t.Errorf("Expected response status code %d, got %d", http.StatusInternalServerError, w.Code)
+			}
+		})
+	}
+}
+
+func TestReleaseBuffer(t *testing.T) {
+	t.Parallel()
+	buf := newBuffer()
+	buf.Grow(maxPooledBufferSize + 1)
+	buf.WriteString("hello")
+	releaseBuffer(buf)
+	// oversized buffers are dropped instead of being reset and pooled
+	if buf.Len() != len("hello") {
+		t.Errorf("Expected oversized buffer to be left untouched, got length %d", buf.Len())
+	}
+
+	buf = newBuffer()
+	buf.WriteString("hello")
+	releaseBuffer(buf)
+	if buf.Len() != 0 {
+		t.Errorf("Expected pooled buffer to be reset, got length %d", buf.Len())
+	}
+}
+
+func TestStatusHelpers(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		send   func(w http.ResponseWriter)
+		code   int
+		header string
+		value  string
+	}{
+		{
+			name:   "R201 with location",
+			send:   func(w http.ResponseWriter) { R201(w, "created", "/users/42") },
+			code:   http.StatusCreated,
+			header: HeaderLocation,
+			value:  "/users/42",
+		},
+		{
+			name:   "R401 default challenge",
+			send:   func(w http.ResponseWriter) { R401(w, "login", "") },
+			code:   http.StatusUnauthorized,
+			header: HeaderWWWAuthenticate,
+			value:  "Bearer",
+		},
+		{
+			name:   "R401",
+			send:   func(w http.ResponseWriter) { R401(w, "login", `Basic realm="web"`) },
+			code:   http.StatusUnauthorized,
+			header: HeaderWWWAuthenticate,
+			value:  `Basic realm="web"`,
+		},
+		{
+			name: "R409",
+			send: func(w http.ResponseWriter) { R409(w, "conflict") },
+			code: http.StatusConflict,
+		},
+		{
+			name: "R410",
+			send: func(w http.ResponseWriter) { R410(w, "gone") },
+			code: http.StatusGone,
+		},
+		{
+			name: "R413",
+			send: func(w http.ResponseWriter) { R413(w, "too large") },
+			code: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "R415",
+			send: func(w http.ResponseWriter) { R415(w, "unsupported") },
+			code: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "R422",
+			send: func(w http.ResponseWriter) { R422(w, "invalid") },
+			code: http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "R429",
+			send:   func(w http.ResponseWriter) { R429(w, "slow down", 1500*time.Millisecond) },
+			code:   http.StatusTooManyRequests,
+			header: HeaderRetryAfter,
+			value:  "2",
+		},
+		{
+			name:   "R503 without retry",
+			send:   func(w http.ResponseWriter) { R503(w, "maintenance", 0) },
+			code:   http.StatusServiceUnavailable,
+			header: HeaderRetryAfter,
+			value:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.send(w)
+			if w.Code != tt.code {
+				t.Errorf("Expected response status code %d, got %d", tt.code, w.Code)
+			}
+			if tt.header != "" && w.Header().Get(tt.header) != tt.value {
+				t.Errorf("Expected header %s '%s', got '%s'", tt.header, tt.value, w.Header().Get(tt.header))
+			}
+			resp := struct {
+				Status int
+			}{}
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			if err != nil || resp.Status != tt.code {
+				t.Errorf("Expected status %d in body, got '%s'", tt.code, w.Body.String())
+			}
+		})
+	}
+
+	// R204 status is tracked by the custom response writer
+	w := newCRW(httptest.NewRecorder(), http.StatusOK)
+	R204(w)
+	if ResponseStatus(w) != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, ResponseStatus(w))
+	}
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// envRestartPPID is the PID of the parent process which handed its listeners over on restart
+	envRestartPPID = "WEB_RESTART_PPID"
+	// envRestartReadyFD is the file descriptor the restarted process writes to, once it is serving
+	envRestartReadyFD = "WEB_RESTART_READY_FD"
+
+	// defaultRestartTimeout is the time the new process has to get ready, if ShutdownTimeout is not configured
+	defaultRestartTimeout = 30 * time.Second
+)
+
+// ErrRestartUnsupported is the error returned when the listeners cannot be handed over to a new process
+var ErrRestartUnsupported = errors.New("graceful restart is not supported")
+
+// restartedListeners returns the listeners handed over by the parent process, if the process was
+// started by a graceful restart. The listeners are passed the same way as systemd socket activation.
+func restartedListeners() ([]net.Listener, []string, error) {
+	ppid := os.Getenv(envRestartPPID)
+	if ppid == "" || ppid != strconv.Itoa(os.Getppid()) {
+		return nil, nil, nil
+	}
+	defer func() {
+		_ = os.Unsetenv(envRestartPPID)
+		_ = os.Unsetenv(envListenFDs)
+		_ = os.Unsetenv(envListenFDNames)
+	}()
+
+	// the PID of the new process is not known before it is started, so the parent PID is checked instead
+	return activatedListeners(func(key string) string {
+		if key == envListenPID {
+			return strconv.Itoa(os.Getpid())
+		}
+		return os.Getenv(key)
+	}, listenFDsStart)
+}
+
+// notifyRestarted lets the parent process know that the restarted process is serving,
+// so that the parent can shut down. It does nothing if the process was not started by a graceful restart.
+func notifyRestarted() {
+	readyFD := os.Getenv(envRestartReadyFD)
+	if readyFD == "" {
+		return
+	}
+	_ = os.Unsetenv(envRestartReadyFD)
+
+	fd, err := strconv.Atoi(readyFD)
+	if err != nil {
+		LOGHANDLER.Error(err)
+		return
+	}
+	ready := os.NewFile(uintptr(fd), "ready")
+	_, err = ready.Write([]byte{1})
+	if err != nil {
+		LOGHANDLER.Error(err)
+	}
+	_ = ready.Close()
+}
+
+// restartEnv returns the environment of the current process, without the listener handoff variables
+func restartEnv() []string {
+	env := os.Environ()
+	filtered := make([]string, 0, len(env)+4)
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		switch key {
+		case envListenPID, envListenFDs, envListenFDNames, envRestartPPID, envRestartReadyFD:
+			continue
+		}
+		filtered = append(filtered, kv)
+	}
+	return filtered
+}
+
+// restartTimeout returns the time the restarted process has to get ready
+func (router *Router) restartTimeout() time.Duration {
+	if router.config.ShutdownTimeout > 0 {
+		return router.config.ShutdownTimeout
+	}
+	return defaultRestartTimeout
+}
+
+// restartExecutable returns the executable started on graceful restart
+func (router *Router) restartExecutable() (string, error) {
+	if router.config.RestartExecutable != "" {
+		return router.config.RestartExecutable, nil
+	}
+	// the path is resolved before the binary is replaced by a deploy,
+	// since the running executable may no longer be found by its path afterwards
+	return os.Executable()
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package web
+
+import (
+	"net"
+	"os"
+	"time"
+)
+
+// restartSignals returns the signals which trigger a graceful restart, there are none on this platform
+func restartSignals() []os.Signal {
+	return nil
+}
+
+// handoff is not supported on this platform
+func handoff(exe string, args []string, timeout time.Duration, httpL net.Listener, httpsL net.Listener) error {
+	return ErrRestartUnsupported
+}
+
+// keepSocketFiles does nothing on this platform
+func keepSocketFiles(listeners ...net.Listener) {}
@@ -0,0 +1,112 @@
+//go:build unix
+
+package web
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// restartSignals returns the signals which trigger a graceful restart
+func restartSignals() []os.Signal {
+	return []os.Signal{syscall.SIGUSR2}
+}
+
+// listenerFiler is implemented by the listeners whose file descriptor can be handed over
+type listenerFiler interface {
+	File() (*os.File, error)
+}
+
+// handoff starts the executable with the args, handing the listeners (HTTP first, then HTTPS) over to it.
+// Nil listeners are skipped. It returns once the new process is serving, or an error if it fails to get ready
+// within the timeout, in which case the new process is killed and the listeners are kept.
+func handoff(exe string, args []string, timeout time.Duration, httpL net.Listener, httpsL net.Listener) error {
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := startHandoff(cmd, timeout, httpL, httpsL)
+	if err != nil {
+		return err
+	}
+
+	LOGHANDLER.Info("graceful restart, new process", cmd.Process.Pid, "is serving")
+	return cmd.Process.Release()
+}
+
+// startHandoff starts cmd, handing the listeners over to it, and waits until it is serving
+func startHandoff(cmd *exec.Cmd, timeout time.Duration, httpL net.Listener, httpsL net.Listener) error {
+	files := make([]*os.File, 0, 3)
+	names := make([]string, 0, 2)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	for i, l := range []net.Listener{httpL, httpsL} {
+		if l == nil {
+			continue
+		}
+		filer, ok := l.(listenerFiler)
+		if !ok {
+			return fmt.Errorf("%w: listener %T", ErrRestartUnsupported, l)
+		}
+		f, err := filer.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		names = append(names, []string{"http", "https"}[i])
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+	files = append(files, readyW)
+
+	// the extra files are the file descriptors 3, 4... of the new process
+	cmd.ExtraFiles = files
+	cmd.Env = append(
+		restartEnv(),
+		envListenFDs+"="+strconv.Itoa(len(names)),
+		envListenFDNames+"="+strings.Join(names, ":"),
+		envRestartPPID+"="+strconv.Itoa(os.Getpid()),
+		envRestartReadyFD+"="+strconv.Itoa(listenFDsStart+len(names)),
+	)
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	// the write end is closed in this process, so that reading fails if the new process exits
+	_ = readyW.Close()
+	files = files[:len(files)-1]
+
+	_ = readyR.SetReadDeadline(time.Now().Add(timeout))
+	_, err = readyR.Read(make([]byte, 1))
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("restarted process is not ready: %w", err)
+	}
+	return nil
+}
+
+// keepSocketFiles prevents the Unix domain socket files from being removed when the listeners are closed,
+// since the new process keeps serving on them
+func keepSocketFiles(listeners ...net.Listener) {
+	for _, l := range listeners {
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+//go:build unix
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const envRestartHelper = "WEB_TEST_RESTART_HELPER"
+
+// TestRestartHelper is run in the process started by the graceful restart in TestHandoff
+func TestRestartHelper(t *testing.T) {
+	if os.Getenv(envRestartHelper) == "" {
+		t.Skip("only run by TestHandoff")
+	}
+	router := NewRouter(&Config{ShutdownTimeout: time.Second}, &Route{
+		Name:    "process",
+		Method:  http.MethodGet,
+		Pattern: "/process",
+		Handlers: []http.HandlerFunc{
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("child"))
+			},
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err := router.Run(ctx)
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestHandoff(t *testing.T) {
+	// the environment is inherited by the new process, so the test cannot be parallel
+	t.Setenv(envRestartHelper, "1")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + l.Addr().String() + "/process"
+	router := NewRouter(&Config{ShutdownTimeout: time.Second}, &Route{
+		Name:    "process",
+		Method:  http.MethodGet,
+		Pattern: "/process",
+		Handlers: []http.HandlerFunc{
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("parent"))
+			},
+		},
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- router.Serve(l)
+	}()
+
+	get := func() string {
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Error(err.Error())
+			return ""
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+	if got := get(); got != "parent" {
+		t.Errorf("Expected 'parent', got '%s'", got)
+	}
+
+	// the output of the new process is kept apart from the output of the test
+	output := &bytes.Buffer{}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRestartHelper$")
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = startHandoff(cmd, time.Second*10, l, nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	defer func() {
+		// the new process shuts down gracefully on SIGTERM
+		_ = cmd.Process.Signal(syscall.SIGTERM)
+		err := cmd.Wait()
+		if err != nil {
+			t.Errorf("Expected the new process to exit cleanly, got '%v'. Output: %s", err, output.String())
+		}
+	}()
+
+	_ = router.Shutdown()
+	<-done
+	if got := get(); got != "child" {
+		t.Errorf("Expected the new process to serve on the handed over listener, got '%s'", got)
+	}
+}
+
+func TestHandoff_NotReady(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the process exits without serving
+	err = handoff("/bin/sh", []string{"-c", "exit 0"}, time.Second*5, l, nil)
+	if err == nil {
+		t.Error("Expected an error if the new process is not ready, got nil")
+	}
+}
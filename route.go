@@ -110,16 +110,19 @@ func (r *Route) setupMiddleware(reverse bool) {
 
 func routeServeChainedHandlers(r *Route) http.HandlerFunc {
 	return func(rw http.ResponseWriter, req *http.Request) {
-		crw, ok := rw.(*customResponseWriter)
-		if !ok {
+		// the response writer may be wrapped by middleware, in which case
+		// the handlers should keep writing through the wrapper
+		crw := findCRW(rw)
+		if crw == nil {
 			crw = newCRW(rw, http.StatusOK)
+			rw = crw
 		}
 
 		for _, handler := range r.Handlers {
 			if crw.written && !r.FallThroughPostResponse {
 				break
 			}
-			handler(crw, req)
+			handler(rw, req)
 		}
 	}
 }
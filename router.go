@@ -7,7 +7,11 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
@@ -49,13 +53,41 @@ type Router struct {
 	// NotImplemented is the generic handler for 501 method not implemented
 	NotImplemented http.HandlerFunc
 
+	// ResponseEnvelope wraps the payload of SendResponse, SendError and all the R-helpers.
+	// DefaultEnvelope is used if it is nil
+	ResponseEnvelope ResponseEnvelope
+	// JSONCodec is used to encode all the JSON responses. StdJSONCodec is used if it is nil
+	JSONCodec JSONCodec
+	// DisableHTMLEscape, if true, will not escape problematic HTML characters in JSON responses
+	DisableHTMLEscape bool
+	// ETags, if true, successful responses to GET and HEAD requests sent with the response helpers
+	// get an ETag computed from the encoded body, and If-None-Match is answered with 304 Not Modified
+	ETags bool
+
 	// config has all the app config
 	config *Config
 
+	// serverLock guards the servers, which are set up when the router is started
+	serverLock sync.RWMutex
 	// httpServer is the server handler for the active HTTP server
 	httpServer *http.Server
 	// httpsServer is the server handler for the active HTTPS server
 	httpsServer *http.Server
+	// certs serves the certificates of the active HTTPS server
+	certs *CertReloader
+	// shuttingDown is set once the shutdown of the servers started
+	shuttingDown atomic.Bool
+	// shutdownOnce waits for ShutdownDelay, all the shutdowns started meanwhile wait for it as well.
+	// It is guarded by serverLock and reset when the servers are set up
+	shutdownOnce *sync.Once
+
+	// startHooks, shutdownHooks, requestHooks and responseHooks are the hooks, in the order they were added
+	startHooks    []LifecycleHook
+	shutdownHooks []LifecycleHook
+	requestHooks  []RequestHook
+	responseHooks []ResponseHook
+	// lifecycle tracks the servers started since the start hooks were run
+	lifecycle lifecycle
 }
 
 // Middleware is the signature of Web's middleware
@@ -67,6 +99,19 @@ type customResponseWriter struct {
 	statusCode    int
 	written       bool
 	headerWritten bool
+	// bytesWritten is the number of bytes of the response body written so far
+	bytesWritten int64
+	// firstByteAt is the time when the first byte of the response body was written
+	firstByteAt time.Time
+	// headerWrittenAt is the time when the response header was written
+	headerWrittenAt time.Time
+	// hijacked is true if the connection was hijacked
+	hijacked bool
+	// flushed is true if the response was flushed at least once
+	flushed bool
+	// router and request are used by the response helpers to read the router-level settings
+	router  *Router
+	request *http.Request
 }
 
 // httpResponseWriter has all the functions to be implemented by the custom
@@ -99,7 +144,15 @@ func (crw *customResponseWriter) WriteHeader(code int) {
 		return
 	}
 
+	// informational responses (e.g. 103 Early Hints) are sent before the final response,
+	// so they do not change the status code which is tracked
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		crw.ResponseWriter.WriteHeader(code)
+		return
+	}
+
 	crw.headerWritten = true
+	crw.headerWrittenAt = time.Now()
 	crw.statusCode = code
 	crw.ResponseWriter.WriteHeader(code)
 }
@@ -109,23 +162,40 @@ func (crw *customResponseWriter) WriteHeader(code int) {
 func (crw *customResponseWriter) Write(body []byte) (int, error) {
 	crw.WriteHeader(crw.statusCode)
 	crw.written = true
-	return crw.ResponseWriter.Write(body)
+	if crw.firstByteAt.IsZero() && len(body) > 0 {
+		crw.firstByteAt = time.Now()
+	}
+	n, err := crw.ResponseWriter.Write(body)
+	crw.bytesWritten += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped response writer, so that http.ResponseController
+// and the web helpers can reach the underlying writers.
+func (crw *customResponseWriter) Unwrap() http.ResponseWriter {
+	return crw.ResponseWriter
 }
 
-// Flush calls http.Flusher to clean/flush the buffer.
+// Flush calls http.Flusher to clean/flush the buffer, through the wrapped writers implementing Unwrap.
 func (crw *customResponseWriter) Flush() {
-	if rw, ok := crw.ResponseWriter.(http.Flusher); ok {
-		rw.Flush()
+	err := http.NewResponseController(crw.ResponseWriter).Flush()
+	if err == nil {
+		crw.flushed = true
 	}
 }
 
 // Hijack implements the http.Hijacker interface.
 func (crw *customResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if hj, ok := crw.ResponseWriter.(http.Hijacker); ok {
-		return hj.Hijack()
+		conn, brw, err := hj.Hijack()
+		if err == nil {
+			crw.hijacked = true
+		}
+		return conn, brw, err
 	}
 
-	return nil, nil, errors.New("unable to create hijacker")
+	// HTTP/2 connections cannot be hijacked
+	return nil, nil, fmt.Errorf("unable to create hijacker: %w", http.ErrNotSupported)
 }
 
 // CloseNotify implements the http.CloseNotifier interface
@@ -136,6 +206,9 @@ func (crw *customResponseWriter) CloseNotify() <-chan bool {
 	return nil
 }
 
+// Push implements the http.Pusher interface.
+//
+// Deprecated: browsers no longer support HTTP/2 server push, use EarlyHints instead.
 func (crw *customResponseWriter) Push(target string, opts *http.PushOptions) error {
 	if n, ok := crw.ResponseWriter.(http.Pusher); ok {
 		return n.Push(target, opts)
@@ -147,7 +220,14 @@ func (crw *customResponseWriter) reset() {
 	crw.statusCode = 0
 	crw.written = false
 	crw.headerWritten = false
+	crw.bytesWritten = 0
+	crw.firstByteAt = time.Time{}
+	crw.headerWrittenAt = time.Time{}
+	crw.hijacked = false
+	crw.flushed = false
 	crw.ResponseWriter = nil
+	crw.router = nil
+	crw.request = nil
 }
 
 func releaseCRW(crw *customResponseWriter) {
@@ -202,16 +282,19 @@ func (rtr *Router) Use(mm ...Middleware) {
 }
 
 func (rtr *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	// the custom response writer is used to set the appropriate HTTP status code in case of encoding errors.
-	// i.e. if there is a JSON encoding problem in the response,
-	// the HTTP status code will be 200, and the JSON payload {"status": 500}
+	// the custom response writer keeps track of the HTTP status code of the response,
+	// and carries the router-level settings used by the response helpers
 	crw := newCRW(rw, http.StatusOK)
+	crw.router = rtr
+	crw.request = r
+	rtr.runRequestHooks(crw, r)
 
 	routes := rtr.methodRoutes(r.Method)
 	if routes == nil {
 		// serve 501 when HTTP method is not implemented
 		crw.statusCode = http.StatusNotImplemented
 		rtr.NotImplemented(crw, r)
+		rtr.runResponseHooks(crw, r)
 		releaseCRW(crw)
 		return
 	}
@@ -222,6 +305,7 @@ func (rtr *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 		// serve 404 when there are no matching routes
 		crw.statusCode = http.StatusNotFound
 		rtr.NotFound(crw, r)
+		rtr.runResponseHooks(crw, r)
 		releaseCRW(crw)
 		return
 	}
@@ -229,6 +313,8 @@ func (rtr *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	ctxPayload := newContext()
 	ctxPayload.Route = route
 	ctxPayload.URIParams = params
+	ctxPayload.router = rtr
+	ctxPayload.tlsState = r.TLS
 
 	// web context is injected to the HTTP request context
 	*r = *r.WithContext(
@@ -241,6 +327,7 @@ func (rtr *Router) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 
 	defer releasePoolResources(crw, ctxPayload)
 	route.serve(crw, r)
+	rtr.runResponseHooks(crw, r)
 }
 
 // UseOnSpecialHandlers adds middleware to 2 special web handlers
@@ -376,6 +463,52 @@ func (rtr *Router) Add(routes ...*Route) {
 	rtr.allHandlers = all
 }
 
+// URL returns the URI of the route with the given name, with the named URI parameters replaced
+// by the values in params. e.g. "/api/users/:userID" with params {"userID": "42"} is "/api/users/42".
+// The values of wildcard parameters may contain '/'.
+func (rtr *Router) URL(name string, params map[string]string) (string, error) {
+	var route *Route
+	for _, method := range supportedHTTPMethods {
+		for _, rt := range rtr.allHandlers[method] {
+			if rt.Name == name {
+				route = rt
+				break
+			}
+		}
+		if route != nil {
+			break
+		}
+	}
+	if route == nil {
+		return "", fmt.Errorf("%w: '%s'", ErrRouteNotFound, name)
+	}
+
+	fragments := strings.Split(route.Pattern, "/")
+	for idx, fragment := range fragments {
+		if !strings.Contains(fragment, ":") {
+			continue
+		}
+
+		key := strings.ReplaceAll(fragment, ":", "")
+		key = strings.ReplaceAll(key, "*", "")
+		value, ok := params[key]
+		if !ok {
+			return "", fmt.Errorf("%w: '%s' for the route '%s'", ErrMissingURIParam, key, name)
+		}
+
+		parts := strings.Split(value, "/")
+		if !strings.Contains(fragment, "*") {
+			parts = []string{value}
+		}
+		for i := range parts {
+			parts[i] = url.PathEscape(parts[i])
+		}
+		fragments[idx] = strings.Join(parts, "/")
+	}
+
+	return strings.Join(fragments, "/"), nil
+}
+
 // NewRouter initializes & returns a new router instance with all the configurations and routes set
 func NewRouter(cfg *Config, routes ...*Route) *Router {
 	r := &Router{
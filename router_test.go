@@ -10,28 +10,49 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
+// testLogger records the logs, it is safe for concurrent use since the servers log from their goroutines
 type testLogger struct {
-	out bytes.Buffer
+	lock sync.Mutex
+	out  bytes.Buffer
 }
 
-func (tl *testLogger) Debug(data ...interface{}) {
+func (tl *testLogger) write(data ...interface{}) {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
 	tl.out.Write([]byte(fmt.Sprint(data...)))
 }
+
+func (tl *testLogger) String() string {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	return tl.out.String()
+}
+
+func (tl *testLogger) Reset() {
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	tl.out.Reset()
+}
+
+func (tl *testLogger) Debug(data ...interface{}) {
+	tl.write(data...)
+}
 func (tl *testLogger) Info(data ...interface{}) {
-	tl.out.Write([]byte(fmt.Sprint(data...)))
+	tl.write(data...)
 }
 func (tl *testLogger) Warn(data ...interface{}) {
-	tl.out.Write([]byte(fmt.Sprint(data...)))
+	tl.write(data...)
 }
 func (tl *testLogger) Error(data ...interface{}) {
-	tl.out.Write([]byte(fmt.Sprint(data...)))
+	tl.write(data...)
 }
 func (tl *testLogger) Fatal(data ...interface{}) {
-	tl.out.Write([]byte(fmt.Sprint(data...)))
+	tl.write(data...)
 }
 
 func setup(t *testing.T, port string) (*Router, error) {
@@ -41,8 +62,6 @@ func setup(t *testing.T, port string) (*Router, error) {
 		ReadTimeout:     time.Second * 1,
 		WriteTimeout:    time.Second * 1,
 		ShutdownTimeout: time.Second * 10,
-		CertFile:        "tests/ssl/server.crt",
-		KeyFile:         "tests/ssl/server.key",
 	}
 	router := NewRouter(cfg, getRoutes(t)...)
 	return router, nil
@@ -614,7 +633,7 @@ func Test_httpHandlers(t *testing.T) {
 				Method:  "HELLO",
 			},
 		})
-	got := tl.out.String()
+	got := tl.String()
 	want := "Unsupported HTTP method provided. Method: 'HELLO'"
 	if got != want {
 		t.Errorf(
@@ -623,7 +642,7 @@ func Test_httpHandlers(t *testing.T) {
 			got,
 		)
 	}
-	tl.out.Reset()
+	tl.Reset()
 
 	// empty handlers
 	httpHandlers(
@@ -634,7 +653,7 @@ func Test_httpHandlers(t *testing.T) {
 				Method:  http.MethodGet,
 			},
 		})
-	str := tl.out.String()
+	str := tl.String()
 	want = "provided for the route '/hello/world', method 'GET'"
 	got = str[len(str)-len(want):]
 	if got != want {
@@ -644,7 +663,7 @@ func Test_httpHandlers(t *testing.T) {
 			got,
 		)
 	}
-	tl.out.Reset()
+	tl.Reset()
 }
 
 func TestWildcardMadness(t *testing.T) {
@@ -655,8 +674,6 @@ func TestWildcardMadness(t *testing.T) {
 		ReadTimeout:     time.Second * 1,
 		WriteTimeout:    time.Second * 1,
 		ShutdownTimeout: time.Second * 10,
-		CertFile:        "tests/ssl/server.crt",
-		KeyFile:         "tests/ssl/server.key",
 	}
 	router := NewRouter(cfg, []*Route{
 		{
@@ -0,0 +1,390 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// httpAddr returns the address of the HTTP server
+func (router *Router) httpAddr() string {
+	cfg := router.config
+	if cfg.Listen != "" {
+		return cfg.Listen
+	}
+	addr := cfg.Host
+	if len(cfg.Port) > 0 {
+		return addr + ":" + cfg.Port
+	}
+	return addr + ":http"
+}
+
+// httpsAddr returns the address of the HTTPS server
+func (router *Router) httpsAddr() string {
+	cfg := router.config
+	if cfg.ListenHTTPS != "" {
+		return cfg.ListenHTTPS
+	}
+	addr := cfg.Host
+	if len(cfg.HTTPSPort) > 0 {
+		return addr + ":" + cfg.HTTPSPort
+	}
+	return addr + ":https"
+}
+
+// checkTLSFiles returns an error if the certificate or the key file required for HTTPS is not configured
+func (router *Router) checkTLSFiles() error {
+	cfg := router.config
+	if cfg.CertFile == "" && len(cfg.Certificates) == 0 && !cfg.DevCert {
+		return ErrNoCertificate
+	}
+	if cfg.CertFile != "" && cfg.KeyFile == "" {
+		return ErrNoKeyFile
+	}
+	return nil
+}
+
+// newServer returns a server for the address and the handler, with the settings of the config
+func (router *Router) newServer(addr string, handler http.Handler) *http.Server {
+	cfg := router.config
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ConnState:         cfg.ConnState,
+		BaseContext:       cfg.BaseContext,
+		ConnContext:       cfg.ConnContext,
+		ErrorLog:          serverErrorLog(),
+	}
+	if cfg.DisableKeepAlives {
+		srv.SetKeepAlivesEnabled(false)
+	}
+	return srv
+}
+
+// servers returns the HTTP and HTTPS servers, which are nil if the router was not started
+func (router *Router) servers() (*http.Server, *http.Server) {
+	router.serverLock.RLock()
+	defer router.serverLock.RUnlock()
+	return router.httpServer, router.httpsServer
+}
+
+// serve serves HTTP on the listener, it returns nil once the server is shut down
+func (router *Router) serve(l net.Listener) error {
+	srv, _ := router.servers()
+
+	LOGHANDLER.Info("HTTP server, listening on", l.Addr().String())
+	err := srv.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// serveTLS serves HTTPS on the listener, it returns nil once the server is shut down.
+// The certificates are reloaded while the server is running, see CertReloader.
+func (router *Router) serveTLS(l net.Listener) error {
+	tlsConfig, err := router.config.tlsConfig()
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	pairs, err := router.config.certPairs()
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	certs, err := NewCertReloader(pairs...)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	tlsConfig.GetCertificate = certs.GetCertificate
+
+	router.serverLock.Lock()
+	srv := router.httpsServer
+	router.certs = certs
+	srv.TLSConfig = tlsConfig
+	router.serverLock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.RegisterOnShutdown(cancel)
+	go certs.Watch(ctx, router.config.CertReloadInterval)
+
+	LOGHANDLER.Info("HTTPS server, listening on", l.Addr().String())
+	err = srv.ServeTLS(l, "", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// listenAndServe starts the HTTP server, it returns nil once the server is shut down.
+// The start hooks are run once listening, so that they are not run if the address is in use
+func (router *Router) listenAndServe() error {
+	l, err := listen(router.httpAddr(), router.config.SocketMode, router.config.TCPKeepAlive)
+	if err != nil {
+		return err
+	}
+	return router.Serve(l)
+}
+
+// listenAndServeTLS starts the HTTPS server, it returns nil once the server is shut down
+func (router *Router) listenAndServeTLS() error {
+	l, err := listen(router.httpsAddr(), router.config.SocketMode, router.config.TCPKeepAlive)
+	if err != nil {
+		return err
+	}
+	return router.ServeTLS(l)
+}
+
+// Serve serves HTTP on the listener, e.g. a Unix domain socket or a listener passed by systemd.
+// It blocks until the server fails or is shut down with Shutdown, in which case it returns nil.
+func (router *Router) Serve(l net.Listener) error {
+	router.setupServer()
+	err := router.startLifecycle(context.Background(), serverHTTP)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	return router.serveFailed(serverHTTP, router.serve(l))
+}
+
+// ServeTLS serves HTTPS on the listener, with the configured certificate and key files.
+// It blocks until the server fails or is shut down with ShutdownHTTPS, in which case it returns nil.
+func (router *Router) ServeTLS(l net.Listener) error {
+	err := router.checkTLSFiles()
+	if err != nil {
+		return err
+	}
+	router.setupServer()
+	err = router.startLifecycle(context.Background(), serverHTTPS)
+	if err != nil {
+		_ = l.Close()
+		return err
+	}
+	return router.serveFailed(serverHTTPS, router.serveTLS(l))
+}
+
+// listeners returns the listeners of the HTTP and HTTPS servers, either passed by systemd socket activation,
+// or listening on the configured addresses. A listener is nil if the server should not be started.
+func (router *Router) listeners() (net.Listener, net.Listener, error) {
+	cfg := router.config
+	restarted, names, err := restartedListeners()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(restarted) > 0 {
+		httpL, httpsL := pickActivated(restarted, names)
+		return httpL, httpsL, nil
+	}
+
+	if cfg.SocketActivation {
+		activated, names, err := SystemdListeners()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(activated) > 0 {
+			httpL, httpsL := pickActivated(activated, names)
+			if httpsL != nil {
+				err = router.checkTLSFiles()
+			}
+			if err != nil {
+				closeListeners(httpL, httpsL)
+				return nil, nil, err
+			}
+			return httpL, httpsL, nil
+		}
+	}
+
+	runHTTPS := cfg.HTTPSPort != "" || cfg.ListenHTTPS != ""
+	runHTTP := cfg.Port != "" || cfg.Listen != "" || !runHTTPS
+	var httpL, httpsL net.Listener
+	if runHTTPS {
+		err := router.checkTLSFiles()
+		if err != nil {
+			return nil, nil, err
+		}
+		httpsL, err = listen(router.httpsAddr(), cfg.SocketMode, cfg.TCPKeepAlive)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if runHTTP {
+		var err error
+		httpL, err = listen(router.httpAddr(), cfg.SocketMode, cfg.TCPKeepAlive)
+		if err != nil {
+			closeListeners(httpsL)
+			return nil, nil, err
+		}
+	}
+	return httpL, httpsL, nil
+}
+
+// closeListeners closes the listeners which are not nil
+func closeListeners(listeners ...net.Listener) {
+	for _, l := range listeners {
+		if l != nil {
+			_ = l.Close()
+		}
+	}
+}
+
+// ShuttingDown reports whether the shutdown of the servers started, e.g. to fail the readiness checks
+func (router *Router) ShuttingDown() bool {
+	return router.shuttingDown.Load()
+}
+
+// startShutdown marks the router as shutting down and waits for ShutdownDelay, so that the load balancers
+// stop sending requests before the listeners are closed. The concurrent callers, e.g. Shutdown and ShutdownHTTPS,
+// wait for the same delay, and the later ones return at once
+func (router *Router) startShutdown() {
+	router.serverLock.Lock()
+	if router.shutdownOnce == nil {
+		router.shutdownOnce = &sync.Once{}
+	}
+	once := router.shutdownOnce
+	router.serverLock.Unlock()
+
+	once.Do(func() {
+		if router.shuttingDown.Swap(true) {
+			// e.g. after a graceful restart, the new process is already accepting connections
+			return
+		}
+		delay := router.config.ShutdownDelay
+		if delay > 0 {
+			LOGHANDLER.Info("shutting down, closing the listeners in", delay.String())
+			time.Sleep(delay)
+		}
+	})
+}
+
+// shutdownServer gracefully shuts down the server, if it was set up
+func shutdownServer(ctx context.Context, srv *http.Server) error {
+	if srv == nil {
+		return nil
+	}
+	err := srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		// the connections which are still active, e.g. SSE streams, are closed,
+		// so that their request contexts are cancelled
+		_ = srv.Close()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Run starts the HTTP server, and the HTTPS server if HTTPSPort or ListenHTTPS is configured, and blocks
+// until ctx is cancelled, SIGINT or SIGTERM is received, or either server fails. The servers are then
+// shut down gracefully within ShutdownTimeout. The HTTP server is started if Port or Listen is configured,
+// or if there is no HTTPS server. With SocketActivation, the listeners passed by systemd are used instead.
+// With GracefulRestart, the listeners are handed over to a new process on SIGUSR2, before shutting down.
+// It returns the first fatal error of the servers, or the error of the shutdown.
+func (router *Router) Run(ctx context.Context) error {
+	httpL, httpsL, err := router.listeners()
+	if err != nil {
+		return err
+	}
+
+	exe := ""
+	restart := make(chan os.Signal, 1)
+	if router.config.GracefulRestart {
+		exe, err = router.restartExecutable()
+		if err != nil {
+			closeListeners(httpL, httpsL)
+			return err
+		}
+		sigs := restartSignals()
+		if len(sigs) == 0 {
+			closeListeners(httpL, httpsL)
+			return ErrRestartUnsupported
+		}
+		signal.Notify(restart, sigs...)
+		defer signal.Stop(restart)
+	}
+
+	router.setupServer()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = router.startLifecycle(ctx, serverHTTP, serverHTTPS)
+	if err != nil {
+		closeListeners(httpL, httpsL)
+		return err
+	}
+
+	running := 0
+	errs := make(chan error, 2)
+	if httpL != nil {
+		running++
+		go func() {
+			errs <- router.serve(httpL)
+		}()
+	}
+	if httpsL != nil {
+		running++
+		go func() {
+			errs <- router.serveTLS(httpsL)
+		}()
+	}
+
+	notifyRestarted()
+
+wait:
+	for {
+		select {
+		case err = <-errs:
+			// a server failed, or was shut down with Shutdown/ShutdownHTTPS
+			running--
+			break wait
+		case <-ctx.Done():
+			break wait
+		case <-restart:
+			rErr := handoff(exe, os.Args[1:], router.restartTimeout(), httpL, httpsL)
+			if rErr != nil {
+				LOGHANDLER.Error("graceful restart failed:", rErr.Error())
+				continue
+			}
+			keepSocketFiles(httpL, httpsL)
+			// the new process is already accepting connections on the listeners
+			router.shuttingDown.Store(true)
+			break wait
+		}
+	}
+
+	router.startShutdown()
+	sctx, cancel := context.WithTimeout(context.Background(), router.config.ShutdownTimeout)
+	defer cancel()
+	httpServer, httpsServer := router.servers()
+	shutdownErr := errors.Join(
+		shutdownServer(sctx, httpServer),
+		shutdownServer(sctx, httpsServer),
+	)
+
+	for ; running > 0; running-- {
+		serveErr := <-errs
+		if err == nil {
+			err = serveErr
+		}
+	}
+	// the shutdown hooks are run once the servers are done with the in-flight requests
+	shutdownErr = errors.Join(shutdownErr, router.stopLifecycle(sctx, serverHTTP, serverHTTPS))
+	if err != nil {
+		return err
+	}
+	return shutdownErr
+}
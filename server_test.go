@@ -0,0 +1,212 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRouter_Run(t *testing.T) {
+	t.Parallel()
+	router := NewRouter(&Config{
+		Port:            "0",
+		HTTPSPort:       "0",
+		DevCert:         true,
+		DevCertDir:      t.TempDir(),
+		ShutdownTimeout: time.Second,
+	}, getRoutes(t)...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- router.Run(ctx)
+	}()
+
+	time.Sleep(time.Millisecond * 200)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error, got '%v'", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Error("Expected Run to return after the context is cancelled")
+	}
+}
+
+func TestRouter_RunErrors(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	// the subtests run after the test function returns
+	t.Cleanup(func() { _ = l.Close() })
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr error
+	}{
+		{
+			name:    "missing certificate",
+			cfg:     &Config{HTTPSPort: "0", KeyFile: "server.key"},
+			wantErr: ErrNoCertificate,
+		},
+		{
+			name:    "missing key file",
+			cfg:     &Config{HTTPSPort: "0", CertFile: "server.crt"},
+			wantErr: ErrNoKeyFile,
+		},
+		{
+			name: "port in use",
+			cfg:  &Config{Host: "127.0.0.1", Port: port, ShutdownTimeout: time.Second},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			router := NewRouter(tt.cfg, &Route{
+				Name:     "home",
+				Method:   http.MethodGet,
+				Pattern:  "/",
+				Handlers: []http.HandlerFunc{dummyHandler},
+			})
+			err := router.Run(context.Background())
+			if err == nil {
+				t.Error("Expected an error, got nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error '%v', got '%v'", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+type connKey struct{}
+
+func TestRouter_ServerSettings(t *testing.T) {
+	t.Parallel()
+	var states int32
+	router := NewRouter(&Config{
+		ReadHeaderTimeout: time.Second,
+		IdleTimeout:       time.Second,
+		MaxHeaderBytes:    1024,
+		DisableKeepAlives: true,
+		ShutdownTimeout:   time.Second,
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt32(&states, 1)
+			}
+		},
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, c.RemoteAddr().String())
+		},
+	}, &Route{
+		Name:    "conn",
+		Method:  http.MethodGet,
+		Pattern: "/conn",
+		Handlers: []http.HandlerFunc{
+			func(w http.ResponseWriter, r *http.Request) {
+				addr, _ := r.Context().Value(connKey{}).(string)
+				_, _ = w.Write([]byte(addr))
+			},
+		},
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- router.Serve(l)
+	}()
+	defer func() {
+		_ = router.Shutdown()
+		<-done
+	}()
+
+	srv := router.newServer(l.Addr().String(), router)
+	if srv.ReadHeaderTimeout != time.Second || srv.IdleTimeout != time.Second || srv.ErrorLog == nil {
+		t.Errorf("Expected the server settings of the config, got %+v", srv)
+	}
+
+	url := "http://" + l.Addr().String() + "/conn"
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) == "" {
+		t.Error("Expected the connection context in the request context")
+	}
+	if !resp.Close {
+		t.Error("Expected the connection to be closed, with keep-alives disabled")
+	}
+	if atomic.LoadInt32(&states) == 0 {
+		t.Error("Expected ConnState to be called")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 8192))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode)
+	}
+}
+
+func TestServerErrorLog(t *testing.T) {
+	// not parallel, LOGHANDLER is replaced
+	tl := &testLogger{}
+	defer func(lh Logger) { LOGHANDLER = lh }(LOGHANDLER)
+	LOGHANDLER = tl
+
+	serverErrorLog().Printf("http: TLS handshake error from %s: EOF", "127.0.0.1:1234")
+	want := "http: TLS handshake error from 127.0.0.1:1234: EOF"
+	if got := tl.String(); got != want {
+		t.Errorf("Expected '%s', got '%s'", want, got)
+	}
+}
+
+func TestRouter_ConcurrentShutdownDelay(t *testing.T) {
+	t.Parallel()
+	delay := time.Millisecond * 200
+	router := NewRouter(&Config{ShutdownTimeout: time.Second, ShutdownDelay: delay}, getRoutes(t)...)
+	router.setupServer()
+
+	start := time.Now()
+	done := make(chan time.Duration, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			router.startShutdown()
+			done <- time.Since(start)
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if elapsed := <-done; elapsed < delay {
+			t.Errorf("Expected every shutdown to wait for the delay of %s, returned after %s", delay, elapsed)
+		}
+	}
+
+	// once the delay elapsed, the later shutdowns do not wait again
+	start = time.Now()
+	router.startShutdown()
+	if elapsed := time.Since(start); elapsed >= delay {
+		t.Errorf("Expected the later shutdown to return at once, took %s", elapsed)
+	}
+}
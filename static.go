@@ -0,0 +1,391 @@
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderCacheControl is a key to refer to the cache control of the response header
+	HeaderCacheControl = "Cache-Control"
+	// HeaderETag is a key to refer to the entity tag of the response header
+	HeaderETag = "ETag"
+	// HeaderVary is a key to refer to the vary of the response header
+	HeaderVary = "Vary"
+	// HeaderAcceptEncoding is a key to refer to the accepted encodings of the request header
+	HeaderAcceptEncoding = "Accept-Encoding"
+	// HeaderContentEncoding is a key to refer to the content encoding of the response header
+	HeaderContentEncoding = "Content-Encoding"
+
+	// octetStreamContentType is the content type of files whose type cannot be detected
+	octetStreamContentType = "application/octet-stream"
+	// sniffLen is the number of bytes used to detect the content type, as in http.DetectContentType
+	sniffLen = 512
+
+	staticParam = "filepath"
+)
+
+// precompressed are the supported encodings of precompressed siblings, in the order of preference
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{encoding: "br", extension: ".br"},
+	{encoding: "gzip", extension: ".gz"},
+}
+
+// StaticOptions is used to configure the static file server
+type StaticOptions struct {
+	// Index is the file served for directories, the default is "index.html"
+	Index string
+	// Browse if true, the contents of directories without an index file are listed
+	Browse bool
+	// SPAFallback if true, the root index file is served for paths which do not exist
+	// and do not have a file extension, so that a single page application can handle the routing
+	SPAFallback bool
+	// Precompressed if true, the ".br" or ".gz" sibling of a file is served instead of it,
+	// if the sibling exists and the client accepts the encoding
+	Precompressed bool
+	// CacheControl is the Cache-Control header value per file extension, e.g. {".js": "public, max-age=31536000"}
+	CacheControl map[string]string
+	// DefaultCacheControl is the Cache-Control header value for extensions not in CacheControl
+	DefaultCacheControl string
+}
+
+// staticHandler serves the files in fsys
+type staticHandler struct {
+	prefix string
+	fsys   fs.FS
+	opts   StaticOptions
+
+	etagLock sync.RWMutex
+	etags    map[string]etagEntry
+}
+
+// etagEntry caches the ETag of a file, until the file is modified
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+// Static returns the GET and HEAD routes serving the files in fsys, with the URI prefix.
+// e.g. Static("/static", os.DirFS("./public"), nil) serves "./public/css/main.css" at "/static/css/main.css".
+// Strong ETags, conditional requests and byte ranges are supported.
+func Static(prefix string, fsys fs.FS, opts *StaticOptions) []*Route {
+	sh := &staticHandler{
+		prefix: strings.TrimSuffix(prefix, "/"),
+		fsys:   fsys,
+		etags:  map[string]etagEntry{},
+	}
+	if opts != nil {
+		sh.opts = *opts
+	}
+	if sh.opts.Index == "" {
+		sh.opts.Index = "index.html"
+	}
+
+	pattern := sh.prefix + "/:" + staticParam + "*"
+	routes := make([]*Route, 0, 4)
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		if sh.prefix != "" {
+			routes = append(routes, &Route{
+				Name:          fmt.Sprintf("static:%s:%s", method, sh.prefix),
+				Method:        method,
+				Pattern:       sh.prefix,
+				TrailingSlash: true,
+				Handlers:      []http.HandlerFunc{sh.ServeHTTP},
+			})
+		}
+		routes = append(routes, &Route{
+			Name:          fmt.Sprintf("static:%s:%s", method, pattern),
+			Method:        method,
+			Pattern:       pattern,
+			TrailingSlash: true,
+			Handlers:      []http.HandlerFunc{sh.ServeHTTP},
+		})
+	}
+	return routes
+}
+
+// cleanPath returns the name of the file in fsys, for the escaped wildcard URI parameter.
+// ok is false, if the name is not a valid path within fsys.
+func cleanPath(param string) (string, bool) {
+	name, err := url.PathUnescape(param)
+	if err != nil {
+		return "", false
+	}
+
+	if strings.ContainsAny(name, "\\\x00") {
+		return "", false
+	}
+
+	// cleaning a rooted path removes all the ".." elements, so it cannot go above the root
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+func (sh *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	param := ""
+	if cp, ok := contextPayload(r); ok {
+		param = cp.Params()[staticParam]
+	}
+
+	name, ok := cleanPath(param)
+	if !ok {
+		sendNotFound(w, r)
+		return
+	}
+
+	info, err := fs.Stat(sh.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) && sh.opts.SPAFallback && path.Ext(name) == "" {
+		name = sh.opts.Index
+		info, err = fs.Stat(sh.fsys, name)
+	}
+	if err != nil {
+		sendStaticError(w, r, err)
+		return
+	}
+
+	if !info.IsDir() {
+		sh.serveFile(w, r, name, info)
+		return
+	}
+
+	index := path.Join(name, sh.opts.Index)
+	indexInfo, err := fs.Stat(sh.fsys, index)
+	if err == nil && !indexInfo.IsDir() {
+		sh.serveFile(w, r, index, indexInfo)
+		return
+	}
+
+	if !sh.opts.Browse {
+		sendNotFound(w, r)
+		return
+	}
+	sh.serveDir(w, r, name)
+}
+
+// serveFile serves the file with ServeContent, which handles conditional and range requests
+func (sh *staticHandler) serveFile(w http.ResponseWriter, r *http.Request, name string, info fs.FileInfo) {
+	header := w.Header()
+	ext := path.Ext(name)
+	if cc, ok := sh.opts.CacheControl[ext]; ok {
+		header.Set(HeaderCacheControl, cc)
+	} else if sh.opts.DefaultCacheControl != "" {
+		header.Set(HeaderCacheControl, sh.opts.DefaultCacheControl)
+	}
+
+	if ctype := mime.TypeByExtension(ext); ctype != "" {
+		header.Set(HeaderContentType, ctype)
+	}
+
+	served, servedInfo := name, info
+	if sh.opts.Precompressed {
+		header.Add(HeaderVary, HeaderAcceptEncoding)
+		var encoding string
+		served, servedInfo, encoding = sh.precompressed(r, name, info)
+		if encoding != "" {
+			header.Set(HeaderContentEncoding, encoding)
+			if header.Get(HeaderContentType) == "" {
+				// ServeContent would sniff the compressed bytes
+				header.Set(HeaderContentType, sh.detectContentType(name))
+			}
+		}
+	}
+
+	file, err := sh.fsys.Open(served)
+	if err != nil {
+		sendStaticError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	content, err := readSeeker(file)
+	if err != nil {
+		sendStaticError(w, r, err)
+		return
+	}
+
+	etag, err := sh.etag(served, servedInfo, content)
+	if err != nil {
+		sendStaticError(w, r, err)
+		return
+	}
+	header.Set(HeaderETag, etag)
+
+	w = crwAsserter(w, http.StatusOK)
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// precompressed returns the name, info and encoding of the precompressed sibling of the file,
+// matching the request's Accept-Encoding. The file itself is returned if there is none.
+func (sh *staticHandler) precompressed(r *http.Request, name string, info fs.FileInfo) (string, fs.FileInfo, string) {
+	accepted := r.Header.Get(HeaderAcceptEncoding)
+	if accepted == "" {
+		return name, info, ""
+	}
+
+	for _, pc := range precompressedEncodings {
+		if !acceptsEncoding(accepted, pc.encoding) {
+			continue
+		}
+		sibling := name + pc.extension
+		sInfo, err := fs.Stat(sh.fsys, sibling)
+		if err != nil || sInfo.IsDir() {
+			continue
+		}
+		return sibling, sInfo, pc.encoding
+	}
+	return name, info, ""
+}
+
+// detectContentType returns the content type of the file sniffed from its first bytes,
+// application/octet-stream if the file cannot be read
+func (sh *staticHandler) detectContentType(name string) string {
+	file, err := sh.fsys.Open(name)
+	if err != nil {
+		return octetStreamContentType
+	}
+	defer file.Close()
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return octetStreamContentType
+	}
+	return http.DetectContentType(buf[:n])
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header value allows the encoding
+func acceptsEncoding(accepted string, encoding string) bool {
+	for _, part := range strings.Split(accepted, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding != encoding && coding != "*" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(key) == "q" && strings.Trim(strings.TrimSpace(value), "0.") == "" {
+				// q=0 means the encoding is not acceptable
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// readSeeker returns the file as an io.ReadSeeker, reading it into memory if it is not seekable
+func readSeeker(file fs.File) (io.ReadSeeker, error) {
+	if rs, ok := file.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
+
+// etag returns the strong ETag of the file, computed from its content.
+// ETags are cached until the modification time or the size of the file changes.
+func (sh *staticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	sh.etagLock.RLock()
+	entry, ok := sh.etags[name]
+	sh.etagLock.RUnlock()
+	if ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		return entry.etag, nil
+	}
+
+	hash := sha256.New()
+	_, err := io.Copy(hash, content)
+	if err != nil {
+		return "", err
+	}
+	_, err = content.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	etag := strongETag(hash.Sum(nil))
+	sh.etagLock.Lock()
+	sh.etags[name] = etagEntry{modTime: info.ModTime(), size: info.Size(), etag: etag}
+	sh.etagLock.Unlock()
+
+	return etag, nil
+}
+
+// sendNotFound responds with the NotFound handler of the router serving the request, or with R404 if it is not set
+func sendNotFound(w http.ResponseWriter, r *http.Request) {
+	router := RequestRouter(r)
+	if router == nil || router.NotFound == nil {
+		R404(w, ErrNotFound)
+		return
+	}
+	router.NotFound(crwAsserter(w, http.StatusNotFound), r)
+}
+
+// sendStaticError responds with sendNotFound if the file does not exist or is not accessible, otherwise with 500.
+// The headers of the file which could not be served are removed
+func sendStaticError(w http.ResponseWriter, r *http.Request, err error) {
+	header := w.Header()
+	for _, key := range []string{HeaderContentType, HeaderContentEncoding, HeaderCacheControl, HeaderETag} {
+		header.Del(key)
+	}
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		sendNotFound(w, r)
+		return
+	}
+	sendFileError(w, err)
+}
+
+// serveDir lists the contents of the directory
+func (sh *staticHandler) serveDir(w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := fs.ReadDir(sh.fsys, name)
+	if err != nil {
+		sendStaticError(w, r, err)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	base := sh.prefix + "/"
+	if name != "." {
+		base += name + "/"
+	}
+
+	buf := newBuffer()
+	defer releaseBuffer(buf)
+
+	buf.WriteString("<!doctype html>\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		href := (&url.URL{Path: base + entryName}).EscapedPath()
+		fmt.Fprintf(buf, "<a href=\"%s\">%s</a>\n", html.EscapeString(href), html.EscapeString(entryName))
+	}
+	buf.WriteString("</pre>\n")
+
+	writeBuffer(w, http.StatusOK, HTMLContentType, buf)
+}
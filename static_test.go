@@ -0,0 +1,226 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func staticRouter(t *testing.T, opts *StaticOptions) *Router {
+	t.Helper()
+	modTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":       &fstest.MapFile{Data: []byte("<h1>home</h1>"), ModTime: modTime},
+		"css/main.css":     &fstest.MapFile{Data: []byte("body{}"), ModTime: modTime},
+		"js/app.js":        &fstest.MapFile{Data: []byte("console.log(1)"), ModTime: modTime},
+		"js/app.js.gz":     &fstest.MapFile{Data: []byte("gzipped"), ModTime: modTime},
+		"js/app.js.br":     &fstest.MapFile{Data: []byte("brotli"), ModTime: modTime},
+		"docs/a <b>.txt":   &fstest.MapFile{Data: []byte("a"), ModTime: modTime},
+		"docs/sub/c.txt":   &fstest.MapFile{Data: []byte("c"), ModTime: modTime},
+		"letters/abcd.txt": &fstest.MapFile{Data: []byte("abcdefghij"), ModTime: modTime},
+		"notes/README":     &fstest.MapFile{Data: []byte("read me"), ModTime: modTime},
+		"notes/README.gz":  &fstest.MapFile{Data: []byte("\x1f\x8b\x08\x00gzipped"), ModTime: modTime},
+	}
+	return NewRouter(&Config{}, Static("/static", fsys, opts)...)
+}
+
+func serveStatic(router *Router, method string, path string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, nil)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestStatic(t *testing.T) {
+	t.Parallel()
+	router := staticRouter(t, &StaticOptions{
+		CacheControl:        map[string]string{".css": "public, max-age=31536000"},
+		DefaultCacheControl: "no-cache",
+	})
+
+	w := serveStatic(router, http.MethodGet, "/static/css/main.css", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "body{}" {
+		t.Errorf("Expected 200 'body{}', got %d '%s'", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get(HeaderContentType), "text/css") {
+		t.Errorf("Expected CSS content type, got '%s'", w.Header().Get(HeaderContentType))
+	}
+	if w.Header().Get(HeaderCacheControl) != "public, max-age=31536000" {
+		t.Errorf("Unexpected Cache-Control '%s'", w.Header().Get(HeaderCacheControl))
+	}
+	etag := w.Header().Get(HeaderETag)
+	if !strings.HasPrefix(etag, `"`) || len(etag) != 34 {
+		t.Errorf("Expected strong ETag, got '%s'", etag)
+	}
+
+	// conditional requests
+	w = serveStatic(router, http.MethodGet, "/static/css/main.css", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 without body, got %d '%s'", w.Code, w.Body.String())
+	}
+	w = serveStatic(router, http.MethodGet, "/static/css/main.css", http.Header{
+		"If-Modified-Since": {time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)},
+	})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304, got %d", w.Code)
+	}
+
+	// range requests
+	w = serveStatic(router, http.MethodGet, "/static/letters/abcd.txt", http.Header{"Range": {"bytes=2-4"}})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "cde" {
+		t.Errorf("Expected 206 'cde', got %d '%s'", w.Code, w.Body.String())
+	}
+	if w.Header().Get(HeaderCacheControl) != "no-cache" {
+		t.Errorf("Expected default Cache-Control, got '%s'", w.Header().Get(HeaderCacheControl))
+	}
+
+	// index and HEAD
+	w = serveStatic(router, http.MethodHead, "/static/", nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("Expected 200 without body, got %d '%s'", w.Code, w.Body.String())
+	}
+
+	// directory without index, listing disabled
+	w = serveStatic(router, http.MethodGet, "/static/docs/", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+
+	// missing file without SPA fallback
+	w = serveStatic(router, http.MethodGet, "/static/users/42", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
+
+func TestStatic_Traversal(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	public := filepath.Join(dir, "public")
+	err := os.MkdirAll(filepath.Join(public, "css"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "secret.pem"), []byte("secret"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(public, "css", "main.css"), []byte("body{}"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := NewRouter(&Config{}, Static("/static", os.DirFS(public), &StaticOptions{Browse: true})...)
+	paths := []string{
+		"/static/../secret.pem",
+		"/static/css/../../secret.pem",
+		"/static/%2e%2e/secret.pem",
+		"/static/css/..%2f..%2fsecret.pem",
+		"/static/..%5csecret.pem",
+		"/static/css/main.css%00.txt",
+	}
+	for _, p := range paths {
+		w := serveStatic(router, http.MethodGet, p, nil)
+		if w.Body.String() == "secret" {
+			t.Errorf("Path '%s' escaped the static root", p)
+		}
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected 404 for '%s', got %d", p, w.Code)
+		}
+	}
+}
+
+func TestStatic_Options(t *testing.T) {
+	t.Parallel()
+	router := staticRouter(t, &StaticOptions{
+		Browse:        true,
+		SPAFallback:   true,
+		Precompressed: true,
+	})
+
+	w := serveStatic(router, http.MethodGet, "/static/js/app.js", http.Header{HeaderAcceptEncoding: {"gzip, br"}})
+	if w.Body.String() != "brotli" || w.Header().Get(HeaderContentEncoding) != "br" {
+		t.Errorf("Expected brotli sibling, got '%s' (%s)", w.Body.String(), w.Header().Get(HeaderContentEncoding))
+	}
+	if !strings.Contains(w.Header().Get(HeaderContentType), "javascript") {
+		t.Errorf("Expected JavaScript content type, got '%s'", w.Header().Get(HeaderContentType))
+	}
+	if w.Header().Get(HeaderVary) != HeaderAcceptEncoding {
+		t.Errorf("Expected Vary '%s', got '%s'", HeaderAcceptEncoding, w.Header().Get(HeaderVary))
+	}
+
+	w = serveStatic(router, http.MethodGet, "/static/js/app.js", http.Header{HeaderAcceptEncoding: {"gzip, br;q=0"}})
+	if w.Body.String() != "gzipped" || w.Header().Get(HeaderContentEncoding) != "gzip" {
+		t.Errorf("Expected gzip sibling, got '%s' (%s)", w.Body.String(), w.Header().Get(HeaderContentEncoding))
+	}
+
+	w = serveStatic(router, http.MethodGet, "/static/js/app.js", nil)
+	if w.Body.String() != "console.log(1)" || w.Header().Get(HeaderContentEncoding) != "" {
+		t.Errorf("Expected uncompressed file, got '%s'", w.Body.String())
+	}
+
+	// the content type of a file without extension is detected from the uncompressed file
+	w = serveStatic(router, http.MethodGet, "/static/notes/README", http.Header{HeaderAcceptEncoding: {"gzip"}})
+	if w.Header().Get(HeaderContentEncoding) != "gzip" {
+		t.Errorf("Expected gzip sibling, got '%s'", w.Header().Get(HeaderContentEncoding))
+	}
+	if ct := w.Header().Get(HeaderContentType); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Expected the content type of the uncompressed file, got '%s'", ct)
+	}
+
+	// directory listing, with escaped names
+	w = serveStatic(router, http.MethodGet, "/static/docs/", nil)
+	body := w.Body.String()
+	if !strings.Contains(body, `<a href="/static/docs/a%20%3Cb%3E.txt">a &lt;b&gt;.txt</a>`) ||
+		!strings.Contains(body, `<a href="/static/docs/sub/">sub/</a>`) {
+		t.Errorf("Unexpected directory listing '%s'", body)
+	}
+
+	// SPA fallback
+	w = serveStatic(router, http.MethodGet, "/static/users/42", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "<h1>home</h1>" {
+		t.Errorf("Expected index.html, got %d '%s'", w.Code, w.Body.String())
+	}
+	w = serveStatic(router, http.MethodGet, "/static/missing.js", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for missing asset, got %d", w.Code)
+	}
+}
+
+func TestStatic_NotFound(t *testing.T) {
+	t.Parallel()
+	router := staticRouter(t, &StaticOptions{
+		CacheControl: map[string]string{".css": "public, max-age=31536000"},
+	})
+	router.NotFound = func(w http.ResponseWriter, r *http.Request) {
+		Send(w, "text/plain", "custom not found", http.StatusNotFound)
+	}
+
+	paths := []string{"/static/missing.css", "/static/docs/", "/static/..%5csecret.pem"}
+	for _, p := range paths {
+		w := serveStatic(router, http.MethodGet, p, nil)
+		if w.Code != http.StatusNotFound || w.Body.String() != "custom not found" {
+			t.Errorf("%s: expected the NotFound handler of the router, got %d '%s'", p, w.Code, w.Body.String())
+		}
+		if cc := w.Header().Get(HeaderCacheControl); cc != "" {
+			t.Errorf("%s: expected no Cache-Control, got '%s'", p, cc)
+		}
+	}
+
+	router.NotFound = nil
+	w := serveStatic(router, http.MethodGet, "/static/missing.css", nil)
+	if w.Code != http.StatusNotFound || w.Header().Get(HeaderContentType) != JSONContentType {
+		t.Errorf("Expected a 404 JSON response without a NotFound handler, got %d '%s'", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), ErrNotFound) {
+		t.Errorf("Expected '%s' in the body, got '%s'", ErrNotFound, w.Body.String())
+	}
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// NDJSONContentType is the MIME type when the response is newline delimited JSON
+	NDJSONContentType = "application/x-ndjson"
+	// HeaderStreamError is the trailer used to report an error which occurred after streaming started
+	HeaderStreamError = "Stream-Error"
+	// HeaderTrailer is a key to refer to the trailer declaration of the response header
+	HeaderTrailer = "Trailer"
+)
+
+// ErrStreamClosed is the error returned when writing to a JSONStream which is already closed
+var ErrStreamClosed = errors.New("JSON stream already closed")
+
+// JSONStream writes JSON values to the response incrementally, without buffering the whole payload.
+// Values are written as newline delimited JSON (NDJSON), or as elements of a single JSON array.
+// The response envelope is not applied to streamed values.
+type JSONStream struct {
+	// NDJSON if true, values are written as newline delimited JSON instead of a JSON array.
+	// It can be changed only before the first value is written.
+	NDJSON bool
+	// FlushEvery is the number of values after which the response is flushed to the client.
+	// The default is 1, i.e. every value is flushed right away.
+	FlushEvery int
+
+	w       http.ResponseWriter
+	ctx     context.Context
+	rs      *responseSettings
+	enc     JSONStreamEncoder
+	rc      *http.ResponseController
+	count   int
+	started bool
+	closed  bool
+}
+
+// StreamJSON returns a JSONStream writing to w.
+// NDJSON is used if the request's Accept header prefers "application/x-ndjson", otherwise a JSON array.
+// Writing stops as soon as the request context is cancelled.
+func StreamJSON(w http.ResponseWriter, r *http.Request) *JSONStream {
+	return &JSONStream{
+		NDJSON:     prefersNDJSON(r.Header.Get(HeaderAccept)),
+		FlushEvery: 1,
+		w:          w,
+		ctx:        r.Context(),
+		rs:         settingsOf(w),
+	}
+}
+
+// prefersNDJSON reports whether NDJSON is preferred over JSON in the Accept header value
+func prefersNDJSON(accept string) bool {
+	for _, mr := range parseAccept(accept) {
+		if mr.q <= 0 || mr.mainType != "application" {
+			continue
+		}
+		switch mr.subType {
+		case "x-ndjson":
+			return true
+		case "json":
+			return false
+		}
+	}
+	return false
+}
+
+// start writes the response header, declaring the error trailer
+func (js *JSONStream) start() error {
+	js.started = true
+
+	contentType := JSONContentType
+	if js.NDJSON {
+		contentType = NDJSONContentType
+	}
+	header := js.w.Header()
+	header.Set(HeaderContentType, contentType)
+	header.Del(HeaderContentLength)
+	DeclareTrailers(js.w, HeaderStreamError)
+
+	js.w = crwAsserter(js.w, http.StatusOK)
+	js.w.WriteHeader(http.StatusOK)
+	// the response controller finds the flusher through the wrappers implementing Unwrap
+	js.rc = http.NewResponseController(js.w)
+
+	js.enc = js.rs.codec.NewEncoder(js.w)
+	js.enc.SetEscapeHTML(js.rs.escapeHTML)
+
+	if js.NDJSON {
+		return nil
+	}
+	_, err := js.w.Write([]byte("["))
+	return err
+}
+
+// Encode writes v to the stream. It returns the context error if the request was cancelled.
+func (js *JSONStream) Encode(v interface{}) error {
+	if js.closed {
+		return ErrStreamClosed
+	}
+
+	err := js.ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	if !js.started {
+		err = js.start()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !js.NDJSON && js.count > 0 {
+		_, err = js.w.Write([]byte(","))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = js.enc.Encode(v)
+	if err != nil {
+		return err
+	}
+
+	js.count++
+	if js.FlushEvery <= 1 || js.count%js.FlushEvery == 0 {
+		js.Flush()
+	}
+
+	return nil
+}
+
+// Flush sends the values written so far to the client
+func (js *JSONStream) Flush() {
+	if js.rc != nil {
+		_ = js.rc.Flush()
+	}
+}
+
+// Close completes the stream, closing the JSON array if required.
+// If err is not nil and nothing was written yet, a 500 response is sent instead.
+// Otherwise, err is reported to the client through the Stream-Error trailer.
+func (js *JSONStream) Close(err error) error {
+	if js.closed {
+		return ErrStreamClosed
+	}
+	js.closed = true
+
+	if !js.started {
+		if err != nil {
+			R500(js.w, ErrInternalServer)
+			LOGHANDLER.Error(err)
+			return nil
+		}
+
+		startErr := js.start()
+		if startErr != nil {
+			return startErr
+		}
+	}
+
+	if !js.NDJSON {
+		_, wErr := js.w.Write([]byte("]\n"))
+		if wErr != nil {
+			return wErr
+		}
+	}
+
+	if err != nil {
+		// newlines are not allowed in header values
+		msg := strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error())
+		SetTrailer(js.w, HeaderStreamError, msg)
+		LOGHANDLER.Error(err)
+	}
+
+	js.Flush()
+	return nil
+}
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamJSON(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	stream := StreamJSON(w, r)
+	for i := 0; i < 3; i++ {
+		err := stream.Encode(map[string]int{"n": i})
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	err := stream.Close(nil)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	body, _ := ioutil.ReadAll(w.Body)
+	got := []map[string]int{}
+	err = json.Unmarshal(body, &got)
+	if err != nil {
+		t.Errorf("Expected a valid JSON array, got '%s': %v", string(body), err)
+	}
+	if len(got) != 3 || got[2]["n"] != 2 {
+		t.Errorf("Unexpected response '%s'", string(body))
+	}
+	if !w.Flushed {
+		t.Error("Expected response to be flushed")
+	}
+	if w.Header().Get(HeaderContentType) != JSONContentType {
+		t.Errorf("Expected content type '%s', got '%s'", JSONContentType, w.Header().Get(HeaderContentType))
+	}
+
+	// empty array
+	w = httptest.NewRecorder()
+	_ = StreamJSON(w, r).Close(nil)
+	if w.Body.String() != "[]\n" {
+		t.Errorf("Expected '[]', got '%s'", w.Body.String())
+	}
+}
+
+// unwrapOnly wraps the response writer without implementing http.Flusher, like most middleware
+type unwrapOnly struct {
+	http.ResponseWriter
+}
+
+func (uo unwrapOnly) Unwrap() http.ResponseWriter {
+	return uo.ResponseWriter
+}
+
+func TestStreamJSON_Wrapped(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	wrapped := unwrapOnly{w}
+	if _, ok := interface{}(wrapped).(http.Flusher); ok {
+		t.Fatal("Expected the wrapper not to implement http.Flusher")
+	}
+
+	stream := StreamJSON(wrapped, r)
+	err := stream.Encode("hello")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !w.Flushed || w.Body.String() != `["hello"`+"\n" {
+		t.Errorf("Expected the value to be flushed through the wrapper, got '%s' (flushed: %t)", w.Body.String(), w.Flushed)
+	}
+	_ = stream.Close(nil)
+}
+
+func TestStreamJSON_NDJSON(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(HeaderAccept, "application/x-ndjson, application/json;q=0.5")
+
+	stream := StreamJSON(w, r)
+	if !stream.NDJSON {
+		t.Error("Expected NDJSON to be preferred")
+	}
+	_ = stream.Encode(1)
+	_ = stream.Encode("two")
+	_ = stream.Close(errors.New("database\ngone"))
+
+	want := "1\n\"two\"\n"
+	if w.Body.String() != want {
+		t.Errorf("Expected '%s', got '%s'", want, w.Body.String())
+	}
+	if got := w.Result().Trailer.Get(HeaderStreamError); got != "database gone" {
+		t.Errorf("Expected trailer 'database gone', got '%s'", got)
+	}
+	if w.Header().Get(HeaderContentType) != NDJSONContentType {
+		t.Errorf("Expected content type '%s', got '%s'", NDJSONContentType, w.Header().Get(HeaderContentType))
+	}
+	if err := stream.Encode(3); !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("Expected error '%v', got '%v'", ErrStreamClosed, err)
+	}
+}
+
+func TestStreamJSON_Errors(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	stream := StreamJSON(w, r)
+	_ = stream.Encode(1)
+	cancel()
+	if err := stream.Encode(2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error '%v', got '%v'", context.Canceled, err)
+	}
+
+	// error before anything was streamed
+	w = httptest.NewRecorder()
+	stream = StreamJSON(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	_ = stream.Close(errors.New("failed"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected response status code %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
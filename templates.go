@@ -0,0 +1,245 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	// ErrTemplateNotFound is the error returned when there is no page template with the given name
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrNoTemplateFS is the error returned when the template config does not have a file system
+	ErrNoTemplateFS = errors.New("no file system provided for templates")
+	// ErrDuplicateTemplate is the error returned when a page, a layout or a partial have the same file name,
+	// since the templates are named by their file name
+	ErrDuplicateTemplate = errors.New("duplicate template name")
+)
+
+// TemplateConfig is used to configure the HTML templates
+type TemplateConfig struct {
+	// FS is the file system the templates are loaded from, e.g. an embed.FS,
+	// or os.DirFS to read the templates from disk
+	FS fs.FS
+	// Pages is the directory in FS with the page templates.
+	// Each page is registered with its path relative to this directory, without the extension.
+	// e.g. "pages/users/show.html" is registered as "users/show"
+	Pages string
+	// Layouts are glob patterns of the layout templates, which are parsed along with every page
+	Layouts []string
+	// Partials are glob patterns of the partial templates, which are parsed along with every page
+	Partials []string
+	// Layout is the name of the template executed to render a page.
+	// If empty, the page template itself is executed.
+	Layout string
+	// Extension is the file extension of the page templates, the default is ".html"
+	Extension string
+	// Funcs are added to the built-in template functions, and are available in all templates
+	Funcs template.FuncMap
+	// Reload if true, a page is parsed again on every render, so that changes on disk are picked up,
+	// including the pages added or removed since.
+	// It should be enabled only in development, otherwise the parsed templates are cached.
+	Reload bool
+	// Router is used by the "url" template function for reverse routing
+	Router *Router
+}
+
+// Templates is a registry of named HTML page templates
+type Templates struct {
+	cfg   TemplateConfig
+	funcs template.FuncMap
+	pages map[string]*template.Template
+}
+
+// NewTemplates parses all the page templates, along with the layouts and partials.
+// An error is returned if any of the templates cannot be parsed, even if Reload is enabled.
+func NewTemplates(cfg TemplateConfig) (*Templates, error) {
+	if cfg.FS == nil {
+		return nil, ErrNoTemplateFS
+	}
+	if cfg.Extension == "" {
+		cfg.Extension = ".html"
+	}
+	if cfg.Pages == "" {
+		cfg.Pages = "."
+	}
+
+	tpls := &Templates{
+		cfg:   cfg,
+		pages: map[string]*template.Template{},
+	}
+	tpls.funcs = tpls.builtinFuncs()
+	for name, fn := range cfg.Funcs {
+		tpls.funcs[name] = fn
+	}
+
+	paths, err := tpls.pagePaths()
+	if err != nil {
+		return nil, err
+	}
+	for name, fpath := range paths {
+		tpl, err := tpls.parse(fpath)
+		if err != nil {
+			return nil, err
+		}
+		tpls.pages[name] = tpl
+	}
+
+	return tpls, nil
+}
+
+// pagePaths scans the pages directory, and returns the path of each page by name
+func (tpls *Templates) pagePaths() (map[string]string, error) {
+	paths := map[string]string{}
+	err := fs.WalkDir(tpls.cfg.FS, tpls.cfg.Pages, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(fpath) != tpls.cfg.Extension {
+			return nil
+		}
+
+		name := strings.TrimPrefix(fpath, tpls.cfg.Pages+"/")
+		name = strings.TrimSuffix(name, tpls.cfg.Extension)
+		paths[name] = fpath
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// builtinFuncs returns the template functions available in all the templates
+func (tpls *Templates) builtinFuncs() template.FuncMap {
+	return template.FuncMap{
+		// url returns the URI of the named route, e.g. {{url "user" "userID" .ID}}
+		"url": func(name string, keyValues ...string) (string, error) {
+			if tpls.cfg.Router == nil {
+				return "", fmt.Errorf("%w: '%s', no router configured for templates", ErrRouteNotFound, name)
+			}
+			if len(keyValues)%2 != 0 {
+				return "", fmt.Errorf("%w: odd number of key-values for the route '%s'", ErrMissingURIParam, name)
+			}
+			params := make(map[string]string, len(keyValues)/2)
+			for i := 0; i < len(keyValues); i += 2 {
+				params[keyValues[i]] = keyValues[i+1]
+			}
+			return tpls.cfg.Router.URL(name, params)
+		},
+		// dict builds a map from key-value pairs, to pass multiple values to a partial
+		"dict": func(keyValues ...interface{}) (map[string]interface{}, error) {
+			if len(keyValues)%2 != 0 {
+				return nil, errors.New("dict requires an even number of arguments")
+			}
+			dict := make(map[string]interface{}, len(keyValues)/2)
+			for i := 0; i < len(keyValues); i += 2 {
+				key, ok := keyValues[i].(string)
+				if !ok {
+					return nil, fmt.Errorf("dict keys must be strings, got %T", keyValues[i])
+				}
+				dict[key] = keyValues[i+1]
+			}
+			return dict, nil
+		},
+	}
+}
+
+// parse parses the page at fpath along with all the layouts and partials.
+// The templates are named by their file name, an error is returned if two of the files have the same one,
+// rather than one of them silently replacing the other
+func (tpls *Templates) parse(fpath string) (*template.Template, error) {
+	shared := make([]string, 0, len(tpls.cfg.Layouts)+len(tpls.cfg.Partials))
+	for _, pattern := range append(append([]string{}, tpls.cfg.Layouts...), tpls.cfg.Partials...) {
+		matches, err := fs.Glob(tpls.cfg.FS, pattern)
+		if err != nil {
+			return nil, err
+		}
+		shared = append(shared, matches...)
+	}
+
+	files := map[string]string{}
+	for _, file := range append(shared, fpath) {
+		name := path.Base(file)
+		if other, ok := files[name]; ok && other != file {
+			return nil, fmt.Errorf("%w: '%s' and '%s'", ErrDuplicateTemplate, other, file)
+		}
+		files[name] = file
+	}
+
+	tpl := template.New(path.Base(fpath)).Funcs(tpls.funcs)
+	if len(shared) > 0 {
+		var err error
+		tpl, err = tpl.ParseFS(tpls.cfg.FS, shared...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the page is parsed last, so that it can override the blocks defined in the layouts
+	return tpl.ParseFS(tpls.cfg.FS, fpath)
+}
+
+// Lookup returns the page template with the given name.
+// If Reload is enabled, the page is parsed again from the file system, including the pages added since.
+func (tpls *Templates) Lookup(name string) (*template.Template, error) {
+	if tpls.cfg.Reload {
+		fpath := path.Join(tpls.cfg.Pages, name+tpls.cfg.Extension)
+		info, err := fs.Stat(tpls.cfg.FS, fpath)
+		if err != nil || info.IsDir() {
+			return nil, fmt.Errorf("%w: '%s'", ErrTemplateNotFound, name)
+		}
+		return tpls.parse(fpath)
+	}
+
+	tpl, ok := tpls.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrTemplateNotFound, name)
+	}
+	return tpl, nil
+}
+
+// Names returns the names of all the registered pages.
+// If Reload is enabled, the pages directory is scanned again.
+func (tpls *Templates) Names() []string {
+	if tpls.cfg.Reload {
+		paths, err := tpls.pagePaths()
+		if err != nil {
+			LOGHANDLER.Error(err)
+		}
+		names := make([]string, 0, len(paths))
+		for name := range paths {
+			names = append(names, name)
+		}
+		return names
+	}
+
+	names := make([]string, 0, len(tpls.pages))
+	for name := range tpls.pages {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Render renders the page with the given name as the HTML response.
+// The configured Layout is executed if it is defined, otherwise the page itself.
+func (tpls *Templates) Render(w http.ResponseWriter, name string, data interface{}, rCode int) {
+	tpl, err := tpls.Lookup(name)
+	if err != nil {
+		Send(w, "text/plain", ErrInternalServer, http.StatusInternalServerError)
+		LOGHANDLER.Error(err.Error())
+		return
+	}
+
+	if tpls.cfg.Layout != "" {
+		if layout := tpl.Lookup(tpls.cfg.Layout); layout != nil {
+			tpl = layout
+		}
+	}
+
+	Render(w, data, rCode, tpl)
+}
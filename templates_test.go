@@ -0,0 +1,161 @@
+package web
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func templatesFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html": &fstest.MapFile{
+			Data: []byte(`{{define "base"}}<main>{{block "content" .}}default{{end}}</main>{{end}}`),
+		},
+		"partials/user.html": &fstest.MapFile{
+			Data: []byte(`{{define "user"}}<a href="{{url "user" "userID" .ID}}">{{.Name}}</a>{{end}}`),
+		},
+		"pages/home.html": &fstest.MapFile{
+			Data: []byte(`{{define "content"}}{{template "user" .}}{{end}}`),
+		},
+		"pages/users/empty.html": &fstest.MapFile{
+			Data: []byte(`{{define "unused"}}{{end}}`),
+		},
+		"pages/notes.txt": &fstest.MapFile{
+			Data: []byte(`not a template`),
+		},
+	}
+}
+
+func TestNewTemplates(t *testing.T) {
+	t.Parallel()
+	router := NewRouter(&Config{}, &Route{
+		Name:     "user",
+		Method:   http.MethodGet,
+		Pattern:  "/users/:userID",
+		Handlers: []http.HandlerFunc{dummyHandler},
+	})
+
+	tpls, err := NewTemplates(TemplateConfig{
+		FS:       templatesFS(),
+		Pages:    "pages",
+		Layouts:  []string{"layouts/*.html"},
+		Partials: []string{"partials/*.html"},
+		Layout:   "base",
+		Router:   router,
+	})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	names := tpls.Names()
+	sort.Strings(names)
+	if strings.Join(names, ",") != "home,users/empty" {
+		t.Errorf("Expected pages 'home,users/empty', got '%v'", names)
+	}
+
+	w := httptest.NewRecorder()
+	tpls.Render(w, "home", map[string]string{"ID": "4 2", "Name": "<script>alert(1)</script>"}, http.StatusOK)
+	body, _ := ioutil.ReadAll(w.Body)
+	want := `<main><a href="/users/4%202">&lt;script&gt;alert(1)&lt;/script&gt;</a></main>`
+	if string(body) != want {
+		t.Errorf("Expected '%s', got '%s'", want, string(body))
+	}
+	if w.Header().Get(HeaderContentType) != HTMLContentType {
+		t.Errorf("Expected content type '%s', got '%s'", HTMLContentType, w.Header().Get(HeaderContentType))
+	}
+
+	// the layout block default is used when the page does not override it
+	w = httptest.NewRecorder()
+	tpls.Render(w, "users/empty", nil, http.StatusOK)
+	if w.Body.String() != "<main>default</main>" {
+		t.Errorf("Expected '<main>default</main>', got '%s'", w.Body.String())
+	}
+
+	_, err = tpls.Lookup("unknown")
+	if !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Expected error '%v', got '%v'", ErrTemplateNotFound, err)
+	}
+	w = httptest.NewRecorder()
+	tpls.Render(w, "unknown", nil, http.StatusOK)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected response status code %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func TestTemplates_Reload(t *testing.T) {
+	t.Parallel()
+	fsys := fstest.MapFS{
+		"index.html": &fstest.MapFile{Data: []byte(`v1 {{dict "a" 1}}`)},
+	}
+	tpls, err := NewTemplates(TemplateConfig{FS: fsys, Reload: true})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	fsys["index.html"] = &fstest.MapFile{Data: []byte(`v2`)}
+	w := httptest.NewRecorder()
+	tpls.Render(w, "index", nil, http.StatusOK)
+	if w.Body.String() != "v2" {
+		t.Errorf("Expected reloaded template 'v2', got '%s'", w.Body.String())
+	}
+
+	// pages added since are found, and the removed ones are not
+	fsys["users/show.html"] = &fstest.MapFile{Data: []byte(`new page`)}
+	w = httptest.NewRecorder()
+	tpls.Render(w, "users/show", nil, http.StatusOK)
+	if w.Body.String() != "new page" {
+		t.Errorf("Expected the added page 'new page', got '%s'", w.Body.String())
+	}
+	if names := tpls.Names(); len(names) != 2 {
+		t.Errorf("Expected 2 pages, got %v", names)
+	}
+	delete(fsys, "index.html")
+	_, err = tpls.Lookup("index")
+	if !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Expected error '%v' for the removed page, got '%v'", ErrTemplateNotFound, err)
+	}
+
+	// cached templates are not reloaded
+	fsys["index.html"] = &fstest.MapFile{Data: []byte(`v1`)}
+	tpls, _ = NewTemplates(TemplateConfig{FS: fsys})
+	fsys["index.html"] = &fstest.MapFile{Data: []byte(`v2`)}
+	w = httptest.NewRecorder()
+	tpls.Render(w, "index", nil, http.StatusOK)
+	if w.Body.String() != "v1" {
+		t.Errorf("Expected cached template 'v1', got '%s'", w.Body.String())
+	}
+}
+
+func TestNewTemplates_Errors(t *testing.T) {
+	t.Parallel()
+	_, err := NewTemplates(TemplateConfig{})
+	if !errors.Is(err, ErrNoTemplateFS) {
+		t.Errorf("Expected error '%v', got '%v'", ErrNoTemplateFS, err)
+	}
+
+	_, err = NewTemplates(TemplateConfig{
+		FS: fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte(`{{.Hello`)}},
+	})
+	if err == nil {
+		t.Error("Expected parse error, got nil")
+	}
+
+	_, err = NewTemplates(TemplateConfig{
+		FS: fstest.MapFS{
+			"pages/index.html":    &fstest.MapFile{Data: []byte(`page`)},
+			"partials/index.html": &fstest.MapFile{Data: []byte(`partial`)},
+		},
+		Pages:    "pages",
+		Partials: []string{"partials/*.html"},
+	})
+	if !errors.Is(err, ErrDuplicateTemplate) {
+		t.Errorf("Expected error '%v', got '%v'", ErrDuplicateTemplate, err)
+	}
+}
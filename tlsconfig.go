@@ -0,0 +1,192 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// ErrInvalidTLSConfig is the error returned when the TLS settings of the config are invalid
+var ErrInvalidTLSConfig = errors.New("invalid TLS config")
+
+var (
+	tlsVersions = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+	tlsCurves = map[string]tls.CurveID{
+		"X25519": tls.X25519,
+		"P256":   tls.CurveP256,
+		"P384":   tls.CurveP384,
+		"P521":   tls.CurveP521,
+	}
+	tlsClientAuth = map[string]tls.ClientAuthType{
+		"none":               tls.NoClientCert,
+		"request":            tls.RequestClientCert,
+		"require":            tls.RequireAnyClientCert,
+		"verify-if-given":    tls.VerifyClientCertIfGiven,
+		"require-and-verify": tls.RequireAndVerifyClientCert,
+	}
+)
+
+// ClientIdentity is the identity of a client which authenticated with a verified TLS certificate (mutual TLS)
+type ClientIdentity struct {
+	// Subject is the distinguished name of the certificate subject, e.g. "CN=billing,O=Example"
+	Subject string
+	// CommonName is the common name of the certificate subject
+	CommonName string
+	// DNSNames are the DNS names of the subject alternative names
+	DNSNames []string
+	// EmailAddresses are the email addresses of the subject alternative names
+	EmailAddresses []string
+	// URIs are the URIs of the subject alternative names, e.g. SPIFFE IDs
+	URIs []string
+	// IPAddresses are the IP addresses of the subject alternative names
+	IPAddresses []net.IP
+	// Certificate is the verified client certificate
+	Certificate *x509.Certificate
+}
+
+// newClientIdentity returns the identity of the client, or nil if the client certificate was not verified
+func newClientIdentity(state *tls.ConnectionState) *ClientIdentity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	cert := state.VerifiedChains[0][0]
+	uris := make([]string, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+	return &ClientIdentity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		URIs:           uris,
+		IPAddresses:    cert.IPAddresses,
+		Certificate:    cert,
+	}
+}
+
+// tlsVersion returns the TLS version for its name, e.g. "1.2". It returns 0 for an empty name
+func tlsVersion(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[strings.TrimPrefix(name, "TLS")]
+	if !ok {
+		return 0, fmt.Errorf("%w: unknown TLS version '%s'", ErrInvalidTLSConfig, name)
+	}
+	return version, nil
+}
+
+// cipherSuites returns the IDs of the cipher suites, by their names as in tls.CipherSuites.
+// The suites of tls.InsecureCipherSuites are rejected
+func cipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+	insecure := make(map[string]bool)
+	for _, cs := range tls.InsecureCipherSuites() {
+		insecure[cs.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		if insecure[name] {
+			return nil, fmt.Errorf("%w: insecure cipher suite '%s'", ErrInvalidTLSConfig, name)
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown cipher suite '%s'", ErrInvalidTLSConfig, name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// curvePreferences returns the curve IDs, by their names e.g. "X25519"
+func curvePreferences(names []string) ([]tls.CurveID, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	curves := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		curve, ok := tlsCurves[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown curve '%s'", ErrInvalidTLSConfig, name)
+		}
+		curves = append(curves, curve)
+	}
+	return curves, nil
+}
+
+// tlsConfig returns the TLS config of the HTTPS server, built from the config
+func (cfg *Config) tlsConfig() (*tls.Config, error) {
+	tc := &tls.Config{
+		NextProtos: cfg.NextProtos,
+	}
+
+	var err error
+	tc.MinVersion, err = tlsVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	tc.MaxVersion, err = tlsVersion(cfg.TLSMaxVersion)
+	if err != nil {
+		return nil, err
+	}
+	if tc.MinVersion != 0 && tc.MaxVersion != 0 && tc.MinVersion > tc.MaxVersion {
+		return nil, fmt.Errorf("%w: minimum version is greater than the maximum version", ErrInvalidTLSConfig)
+	}
+
+	tc.CipherSuites, err = cipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	tc.CurvePreferences, err = curvePreferences(cfg.CurvePreferences)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ClientAuth != "" {
+		clientAuth, ok := tlsClientAuth[cfg.ClientAuth]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown client auth '%s'", ErrInvalidTLSConfig, cfg.ClientAuth)
+		}
+		tc.ClientAuth = clientAuth
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates in '%s'", ErrInvalidTLSConfig, cfg.ClientCAFile)
+		}
+		tc.ClientCAs = pool
+		if cfg.ClientAuth == "" {
+			// a client CA is only useful if the client certificates are verified
+			tc.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	if tc.ClientAuth >= tls.VerifyClientCertIfGiven && tc.ClientCAs == nil {
+		return nil, fmt.Errorf("%w: client auth '%s' requires a client CA file", ErrInvalidTLSConfig, cfg.ClientAuth)
+	}
+	return tc, nil
+}
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestConfig_TLSConfig(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	caFile, _ := writeTestCert(t, dir, "ca", nil, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr error
+		check   func(*tls.Config) bool
+	}{
+		{
+			name: "versions, suites and curves",
+			cfg: &Config{
+				TLSMinVersion:    "1.2",
+				TLSMaxVersion:    "TLS1.3",
+				CipherSuites:     []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+				CurvePreferences: []string{"X25519", "P256"},
+				NextProtos:       []string{"h2", "http/1.1"},
+			},
+			check: func(tc *tls.Config) bool {
+				return tc.MinVersion == tls.VersionTLS12 && tc.MaxVersion == tls.VersionTLS13 &&
+					len(tc.CipherSuites) == 1 && tc.CipherSuites[0] == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 &&
+					len(tc.CurvePreferences) == 2 && tc.CurvePreferences[0] == tls.X25519 &&
+					len(tc.NextProtos) == 2
+			},
+		},
+		{
+			name: "client CA defaults to verified client certificates",
+			cfg:  &Config{ClientCAFile: caFile},
+			check: func(tc *tls.Config) bool {
+				return tc.ClientAuth == tls.RequireAndVerifyClientCert && tc.ClientCAs != nil
+			},
+		},
+		{
+			name: "optional client certificates",
+			cfg:  &Config{ClientCAFile: caFile, ClientAuth: "verify-if-given"},
+			check: func(tc *tls.Config) bool {
+				return tc.ClientAuth == tls.VerifyClientCertIfGiven
+			},
+		},
+		{name: "unknown version", cfg: &Config{TLSMinVersion: "2.0"}, wantErr: ErrInvalidTLSConfig},
+		{name: "min greater than max", cfg: &Config{TLSMinVersion: "1.3", TLSMaxVersion: "1.2"}, wantErr: ErrInvalidTLSConfig},
+		{name: "unknown cipher suite", cfg: &Config{CipherSuites: []string{"NULL"}}, wantErr: ErrInvalidTLSConfig},
+		{
+			name:    "insecure cipher suite",
+			cfg:     &Config{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			wantErr: ErrInvalidTLSConfig,
+		},
+		{name: "unknown curve", cfg: &Config{CurvePreferences: []string{"P999"}}, wantErr: ErrInvalidTLSConfig},
+		{name: "unknown client auth", cfg: &Config{ClientAuth: "always"}, wantErr: ErrInvalidTLSConfig},
+		{name: "verify without CA", cfg: &Config{ClientAuth: "require-and-verify"}, wantErr: ErrInvalidTLSConfig},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tc, err := tt.cfg.tlsConfig()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected error '%v', got '%v'", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Expected no error, got '%v'", err)
+				return
+			}
+			if !tt.check(tc) {
+				t.Errorf("Unexpected TLS config %+v", tc)
+			}
+		})
+	}
+}
+
+func TestContextPayload_ClientIdentity(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	valid := time.Now().Add(time.Hour)
+	serverCert, serverKey := writeTestCert(t, dir, "server", []string{"localhost"}, valid)
+	clientCert, clientKey := writeTestCert(t, dir, "billing", []string{"billing.internal"}, valid)
+
+	router := NewRouter(&Config{
+		CertFile:        serverCert,
+		KeyFile:         serverKey,
+		ClientCAFile:    clientCert,
+		ClientAuth:      "verify-if-given",
+		ShutdownTimeout: time.Second,
+	}, &Route{
+		Name:    "whoami",
+		Method:  http.MethodGet,
+		Pattern: "/whoami",
+		Handlers: []http.HandlerFunc{
+			func(w http.ResponseWriter, r *http.Request) {
+				id := Context(r).ClientIdentity()
+				if id == nil {
+					_, _ = w.Write([]byte("anonymous"))
+					return
+				}
+				_, _ = w.Write([]byte(id.CommonName + " " + id.DNSNames[0]))
+			},
+		},
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- router.ServeTLS(l)
+	}()
+	defer func() {
+		_ = router.ShutdownHTTPS()
+		<-done
+	}()
+
+	whoami := func(certs []tls.Certificate) string {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true, Certificates: certs},
+		}}
+		resp, err := client.Get("https://" + l.Addr().String() + "/whoami")
+		if err != nil {
+			t.Error(err.Error())
+			return ""
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	if got := whoami(nil); got != "anonymous" {
+		t.Errorf("Expected 'anonymous', got '%s'", got)
+	}
+
+	pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := whoami([]tls.Certificate{pair}); got != "billing billing.internal" {
+		t.Errorf("Expected 'billing billing.internal', got '%s'", got)
+	}
+}
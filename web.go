@@ -14,7 +14,9 @@ package web
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"net/http"
+	"sync"
 )
 
 const wgoCtxKey = ctxkey("webcontext")
@@ -38,6 +40,10 @@ type ContextPayload struct {
 	Route     *Route
 	Err       error
 	URIParams map[string]string
+	// router is the router serving the request, used for reverse routing
+	router *Router
+	// tlsState is the state of the TLS connection of the request, nil for HTTP requests
+	tlsState *tls.ConnectionState
 }
 
 // Params returns the URI parameters of the corresponding route.
@@ -48,6 +54,14 @@ func (cp *ContextPayload) Params() map[string]string {
 func (cp *ContextPayload) reset() {
 	cp.Route = nil
 	cp.Err = nil
+	cp.router = nil
+	cp.tlsState = nil
+}
+
+// ClientIdentity returns the identity of the client, if it authenticated with a verified TLS certificate.
+// It returns nil if the client certificate was not requested or not verified, see Config.ClientAuth.
+func (cp *ContextPayload) ClientIdentity() *ClientIdentity {
+	return newClientIdentity(cp.tlsState)
 }
 
 // SetError sets the value of err in context.
@@ -65,6 +79,13 @@ func Context(r *http.Request) *ContextPayload {
 	return r.Context().Value(wgoCtxKey).(*ContextPayload)
 }
 
+// contextPayload returns the ContextPayload injected inside the HTTP request context,
+// if the request is being served by the router
+func contextPayload(r *http.Request) (*ContextPayload, bool) {
+	cp, ok := r.Context().Value(wgoCtxKey).(*ContextPayload)
+	return cp, ok && cp != nil
+}
+
 // SetError is an auxiliary function for setting an error in the web context
 func SetError(r *http.Request, err error) {
 	ctx := Context(r)
@@ -76,12 +97,21 @@ func GetError(r *http.Request) error {
 	return Context(r).Error()
 }
 
+// RequestRouter returns the router serving the request, nil if the request is not served by a router
+func RequestRouter(r *http.Request) *Router {
+	cp, ok := contextPayload(r)
+	if !ok {
+		return nil
+	}
+	return cp.router
+}
+
 // ResponseStatus returns the response status code.
-// This only works if http.ResponseWriter is not wrapped in
-// another response writer before calling ResponseStatus.
+// It works through any chain of response writers wrapping the web response writer,
+// as long as each of them implements `Unwrap() http.ResponseWriter`.
 func ResponseStatus(rw http.ResponseWriter) int {
-	crw, ok := rw.(*customResponseWriter)
-	if !ok {
+	crw := findCRW(rw)
+	if crw == nil {
 		return http.StatusOK
 	}
 	return crw.statusCode
@@ -90,8 +120,8 @@ func ResponseStatus(rw http.ResponseWriter) int {
 // OriginalResponseWriter returns the Go response record stored in
 // the custom web response record.
 func OriginalResponseWriter(rw http.ResponseWriter) http.ResponseWriter {
-	crw, ok := rw.(*customResponseWriter)
-	if !ok {
+	crw := findCRW(rw)
+	if crw == nil {
 		return nil
 	}
 	return crw.ResponseWriter
@@ -99,20 +129,16 @@ func OriginalResponseWriter(rw http.ResponseWriter) http.ResponseWriter {
 
 func (router *Router) setupServer() {
 	cfg := router.config
-	router.httpsServer = &http.Server{
-		Addr:         "",
-		Handler:      router,
-		ReadTimeout:  cfg.ReadTimeout,
-		WriteTimeout: cfg.WriteTimeout,
-		TLSConfig: &tls.Config{
-			InsecureSkipVerify: cfg.InsecureSkipVerify,
-		},
-	}
-	router.httpServer = &http.Server{
-		Addr:         "",
-		Handler:      router,
-		ReadTimeout:  cfg.ReadTimeout,
-		WriteTimeout: cfg.WriteTimeout,
+	router.serverLock.Lock()
+	defer router.serverLock.Unlock()
+
+	router.shuttingDown.Store(false)
+	router.shutdownOnce = &sync.Once{}
+
+	router.httpsServer = router.newServer(router.httpsAddr(), router.httpsHandler())
+	router.httpServer = router.newServer(router.httpAddr(), router.httpHandler())
+	if cfg.H2C {
+		router.enableH2C(router.httpServer)
 	}
 	router.SetupMiddleware()
 }
@@ -129,61 +155,41 @@ func (router *Router) SetupMiddleware() {
 	}
 }
 
-// StartHTTPS starts the server with HTTPS enabled
+// StartHTTPS starts the server with HTTPS enabled.
+// Errors are logged, use Run to supervise the servers.
 func (router *Router) StartHTTPS() {
-	cfg := router.config
-	if cfg.CertFile == "" {
-		LOGHANDLER.Fatal("No certificate provided for HTTPS")
-	}
-
-	if cfg.KeyFile == "" {
-		LOGHANDLER.Fatal("No key file provided for HTTPS")
-	}
-
-	router.setupServer()
-
-	host := cfg.Host
-	if len(cfg.HTTPSPort) > 0 {
-		host += ":" + cfg.HTTPSPort
+	err := router.checkTLSFiles()
+	if err != nil {
+		LOGHANDLER.Error(err)
+		return
 	}
-	router.httpsServer.Addr = host
 
-	LOGHANDLER.Info("HTTPS server, listening on", router.httpsServer.Addr)
-	err := router.httpsServer.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
-	if err != nil && err != http.ErrServerClosed {
+	err = router.listenAndServeTLS()
+	if err != nil {
 		LOGHANDLER.Error("HTTPS server exited with error:", err.Error())
 	}
 }
 
-// Start starts the HTTP server with the appropriate configurations
+// Start starts the HTTP server with the appropriate configurations.
+// Errors are logged, use Run to supervise the servers.
 func (router *Router) Start() {
-	router.setupServer()
-
-	cfg := router.config
-	host := cfg.Host
-	if len(cfg.Port) > 0 {
-		host += ":" + cfg.Port
-	}
-	router.httpServer.Addr = host
-
-	LOGHANDLER.Info("HTTP server, listening on", router.httpServer.Addr)
-	err := router.httpServer.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
+	err := router.listenAndServe()
+	if err != nil {
 		LOGHANDLER.Error("HTTP server exited with error:", err.Error())
 	}
 }
 
 // Shutdown gracefully shuts down HTTP server
 func (router *Router) Shutdown() error {
-	if router.httpServer == nil {
-		return nil
-	}
-	timer := router.config.ShutdownTimeout
-
-	ctx, cancel := context.WithTimeout(context.TODO(), timer)
+	router.startShutdown()
+	ctx, cancel := context.WithTimeout(context.TODO(), router.config.ShutdownTimeout)
 	defer cancel()
 
-	err := router.httpServer.Shutdown(ctx)
+	httpServer, _ := router.servers()
+	err := errors.Join(
+		shutdownServer(ctx, httpServer),
+		router.stopLifecycle(ctx, serverHTTP),
+	)
 	if err != nil {
 		LOGHANDLER.Error(err)
 	}
@@ -192,16 +198,16 @@ func (router *Router) Shutdown() error {
 
 // ShutdownHTTPS gracefully shuts down HTTPS server
 func (router *Router) ShutdownHTTPS() error {
-	if router.httpsServer == nil {
-		return nil
-	}
-	timer := router.config.ShutdownTimeout
-
-	ctx, cancel := context.WithTimeout(context.TODO(), timer)
+	router.startShutdown()
+	ctx, cancel := context.WithTimeout(context.TODO(), router.config.ShutdownTimeout)
 	defer cancel()
 
-	err := router.httpsServer.Shutdown(ctx)
-	if err != nil && err != http.ErrServerClosed {
+	_, httpsServer := router.servers()
+	err := errors.Join(
+		shutdownServer(ctx, httpsServer),
+		router.stopLifecycle(ctx, serverHTTPS),
+	)
+	if err != nil {
 		LOGHANDLER.Error(err)
 	}
 	return err
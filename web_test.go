@@ -40,6 +40,8 @@ func TestStart(t *testing.T) {
 func TestStartHTTPS(t *testing.T) {
 	t.Parallel()
 	router, _ := setup(t, "8443")
+	router.config.DevCert = true
+	router.config.DevCertDir = t.TempDir()
 	go router.StartHTTPS()
 	time.Sleep(time.Second * 2)
 	err := router.ShutdownHTTPS()
@@ -87,3 +89,24 @@ func TestErrorHandling(t *testing.T) {
 		t.Fatalf("expected err %v, got %v", err, gotErr)
 	}
 }
+
+func TestRequestRouter(t *testing.T) {
+	t.Parallel()
+	var got *Router
+	router := NewRouter(&Config{}, &Route{
+		Name:    "router",
+		Method:  http.MethodGet,
+		Pattern: "/router",
+		Handlers: []http.HandlerFunc{func(w http.ResponseWriter, r *http.Request) {
+			got = RequestRouter(r)
+		}},
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/router", nil))
+	if got != router {
+		t.Errorf("Expected the router serving the request, got %p", got)
+	}
+
+	if got := RequestRouter(httptest.NewRequest(http.MethodGet, "/", nil)); got != nil {
+		t.Errorf("Expected no router outside of the router, got %p", got)
+	}
+}
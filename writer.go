@@ -0,0 +1,108 @@
+package web
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+)
+
+// maxUnwrapDepth limits the number of response writers unwrapped while looking for the web response writer
+const maxUnwrapDepth = 32
+
+// rwUnwrapper is implemented by response writers which wrap another response writer,
+// it is the same interface used by http.ResponseController
+type rwUnwrapper interface {
+	Unwrap() http.ResponseWriter
+}
+
+// findCRW returns the custom response writer of web, looking through the chain
+// of wrapping response writers. It returns nil if there is none.
+func findCRW(w http.ResponseWriter) *customResponseWriter {
+	for i := 0; i < maxUnwrapDepth && w != nil; i++ {
+		if crw, ok := w.(*customResponseWriter); ok {
+			return crw
+		}
+		u, ok := w.(rwUnwrapper)
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+	return nil
+}
+
+// ResponseStats are the details of a response, recorded by the web response writer
+type ResponseStats struct {
+	// Status is the response status code
+	Status int
+	// BytesWritten is the number of bytes of the response body written so far
+	BytesWritten int64
+	// FirstByteAt is the time when the first byte of the response body was written,
+	// it is zero if no body was written yet
+	FirstByteAt time.Time
+	// HeaderWrittenAt is the time when the response header was written,
+	// it is zero if the header was not written yet
+	HeaderWrittenAt time.Time
+	// Hijacked is true if the connection was hijacked, e.g. for websockets.
+	// Status and BytesWritten do not account for what was written on the hijacked connection
+	Hijacked bool
+	// Flushed is true if the response was flushed at least once
+	Flushed bool
+}
+
+// ResponseInfo returns the details of the response, e.g. for access logs and metrics.
+// It works through any chain of response writers wrapping the web response writer,
+// as long as each of them implements `Unwrap() http.ResponseWriter`.
+// ok is false if the response is not written through the web response writer.
+// The writer is reused once the request is served, so ResponseInfo should be called from within the handlers or middleware.
+func ResponseInfo(w http.ResponseWriter) (ResponseStats, bool) {
+	crw := findCRW(w)
+	if crw == nil {
+		return ResponseStats{Status: http.StatusOK}, false
+	}
+	return ResponseStats{
+		Status:          crw.statusCode,
+		BytesWritten:    crw.bytesWritten,
+		FirstByteAt:     crw.firstByteAt,
+		HeaderWrittenAt: crw.headerWrittenAt,
+		Hijacked:        crw.hijacked,
+		Flushed:         crw.flushed,
+	}, true
+}
+
+// ResponseWriterWrapper is a helper for middleware which wrap the response writer, e.g. to compress the body.
+// It should be embedded, overriding only the required methods. Unwrap lets http.ResponseController and
+// the web helpers (e.g. ResponseStatus) reach the wrapped writers, Flush and Hijack are forwarded to them.
+//
+//	type gzipWriter struct {
+//		*web.ResponseWriterWrapper
+//		gz *gzip.Writer
+//	}
+//
+//	func (gw *gzipWriter) Write(b []byte) (int, error) {
+//		return gw.gz.Write(b)
+//	}
+type ResponseWriterWrapper struct {
+	http.ResponseWriter
+}
+
+// NewResponseWriterWrapper returns a ResponseWriterWrapper wrapping w
+func NewResponseWriterWrapper(w http.ResponseWriter) *ResponseWriterWrapper {
+	return &ResponseWriterWrapper{ResponseWriter: w}
+}
+
+// Unwrap returns the wrapped response writer
+func (rww *ResponseWriterWrapper) Unwrap() http.ResponseWriter {
+	return rww.ResponseWriter
+}
+
+// Flush sends any buffered data to the client, if the wrapped writers support it
+func (rww *ResponseWriterWrapper) Flush() {
+	_ = http.NewResponseController(rww.ResponseWriter).Flush()
+}
+
+// Hijack lets the caller take over the connection, if the wrapped writers support it
+func (rww *ResponseWriterWrapper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rww.ResponseWriter).Hijack()
+}
@@ -0,0 +1,154 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upperWriter is a middleware response writer which converts the body to upper case
+type upperWriter struct {
+	*ResponseWriterWrapper
+}
+
+func (uw *upperWriter) Write(b []byte) (int, error) {
+	return uw.ResponseWriterWrapper.Write(bytes.ToUpper(b))
+}
+
+func TestResponseWriterWrapper(t *testing.T) {
+	t.Parallel()
+	var (
+		status int
+		stats  ResponseStats
+		ok     bool
+		orig   http.ResponseWriter
+	)
+	upper := func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		uw := &upperWriter{ResponseWriterWrapper: NewResponseWriterWrapper(w)}
+		next(uw, r)
+		status = ResponseStatus(uw)
+		stats, ok = ResponseInfo(uw)
+		orig = OriginalResponseWriter(uw)
+	}
+
+	router := NewRouter(&Config{}, &Route{
+		Name:    "hello",
+		Method:  http.MethodGet,
+		Pattern: "/hello",
+		Handlers: []http.HandlerFunc{
+			func(w http.ResponseWriter, r *http.Request) {
+				R201(w, "hello")
+				rc := http.NewResponseController(w)
+				if err := rc.Flush(); err != nil {
+					t.Errorf("Expected no error flushing through the wrappers, got '%v'", err)
+				}
+			},
+		},
+	})
+	router.Use(upper)
+	router.SetupMiddleware()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/hello", nil))
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Expected response status code %d, got %d", http.StatusCreated, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"HELLO"`) {
+		t.Errorf("Expected the body to be written through the wrapper, got '%s'", w.Body.String())
+	}
+	if status != http.StatusCreated {
+		t.Errorf("Expected ResponseStatus %d, got %d", http.StatusCreated, status)
+	}
+	if !ok || stats.Status != http.StatusCreated || stats.BytesWritten != int64(w.Body.Len()) || stats.FirstByteAt.IsZero() {
+		t.Errorf("Unexpected response info %+v (ok: %v), body length %d", stats, ok, w.Body.Len())
+	}
+	if orig != w {
+		t.Errorf("Expected the original response writer to be the recorder, got %T", orig)
+	}
+}
+
+func TestFindCRW(t *testing.T) {
+	t.Parallel()
+	w := httptest.NewRecorder()
+	if findCRW(w) != nil {
+		t.Error("Expected no custom response writer for a recorder")
+	}
+	if findCRW(NewResponseWriterWrapper(w)) != nil {
+		t.Error("Expected no custom response writer for a wrapped recorder")
+	}
+
+	crw := newCRW(w, http.StatusAccepted)
+	wrapped := NewResponseWriterWrapper(NewResponseWriterWrapper(crw))
+	if findCRW(wrapped) != crw {
+		t.Error("Expected the custom response writer to be found through the wrappers")
+	}
+
+	// the status is set on the custom response writer, while writing through the wrapper
+	if got := crwAsserter(wrapped, http.StatusConflict); got != wrapped {
+		t.Errorf("Expected crwAsserter to return the wrapper, got %T", got)
+	}
+	if ResponseStatus(wrapped) != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, ResponseStatus(wrapped))
+	}
+
+	if _, ok := ResponseInfo(w); ok {
+		t.Error("Expected ok to be false for a recorder")
+	}
+}
+
+// hijackRecorder is a recorder which can be hijacked
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hr *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	_ = client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func TestResponseInfo(t *testing.T) {
+	t.Parallel()
+	before := time.Now()
+	crw := newCRW(&hijackRecorder{ResponseRecorder: httptest.NewRecorder()}, http.StatusOK)
+	w := NewResponseWriterWrapper(crw)
+
+	info, _ := ResponseInfo(w)
+	if !info.HeaderWrittenAt.IsZero() || !info.FirstByteAt.IsZero() || info.Flushed || info.Hijacked {
+		t.Errorf("Expected empty response info, got %+v", info)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("hello"))
+	_, _ = w.Write([]byte(" world"))
+	w.Flush()
+	conn, _, err := w.Hijack()
+	if err != nil {
+		t.Errorf("Expected no error, got '%v'", err)
+		return
+	}
+	_ = conn.Close()
+
+	info, ok := ResponseInfo(w)
+	if !ok {
+		t.Error("Expected ok to be true")
+	}
+	if info.Status != http.StatusAccepted || info.BytesWritten != 11 || !info.Flushed || !info.Hijacked {
+		t.Errorf("Unexpected response info %+v", info)
+	}
+	if info.HeaderWrittenAt.Before(before) || info.FirstByteAt.Before(info.HeaderWrittenAt) {
+		t.Errorf("Unexpected timestamps, header %v, first byte %v", info.HeaderWrittenAt, info.FirstByteAt)
+	}
+
+	// the writer returned to the pool should not leak the details of the previous response
+	crw.reset()
+	if *crw != (customResponseWriter{}) {
+		t.Errorf("Expected the response writer to be reset, got %+v", *crw)
+	}
+}